@@ -0,0 +1,93 @@
+package nodepb
+
+import (
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+)
+
+// Umwandlung zwischen den Protobuf-Nachrichten und den Typen des blockchain-Pakets.
+// Die Felder werden 1:1 übernommen, Hashes und Signaturen bleiben dadurch prüfbar.
+
+func FromTransaction(tx *blockchain.Transaction) *Transaction {
+	return &Transaction{
+		Hash:    tx.Hash,
+		ChainId: tx.ChainID,
+		Nonce:   tx.Nonce,
+		EncryptedData: &EncryptedData{
+			Ciphertext: tx.EncryptedData.Ciphertext,
+			Nonce:      tx.EncryptedData.Nonce,
+		},
+		Doctor:    tx.Doctor,
+		Patient:   tx.Patient,
+		Signature: fromSignature(tx.Signature),
+		Urgent:    tx.Urgent,
+	}
+}
+
+func ToTransaction(tx *Transaction) *blockchain.Transaction {
+	return &blockchain.Transaction{
+		Hash:    tx.GetHash(),
+		ChainID: tx.GetChainId(),
+		Nonce:   tx.GetNonce(),
+		EncryptedData: utils.EncryptedData{
+			Ciphertext: tx.GetEncryptedData().GetCiphertext(),
+			Nonce:      tx.GetEncryptedData().GetNonce(),
+		},
+		Doctor:    tx.GetDoctor(),
+		Patient:   tx.GetPatient(),
+		Signature: toSignature(tx.GetSignature()),
+		Urgent:    tx.GetUrgent(),
+	}
+}
+
+func FromTransactions(transactions []*blockchain.Transaction) []*Transaction {
+	result := make([]*Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		result = append(result, FromTransaction(tx))
+	}
+	return result
+}
+
+func ToTransactions(transactions []*Transaction) []*blockchain.Transaction {
+	result := make([]*blockchain.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		result = append(result, ToTransaction(tx))
+	}
+	return result
+}
+
+func FromBlock(block *blockchain.Block) *Block {
+	return &Block{
+		Id:           block.ID,
+		Hash:         block.Hash,
+		PreviousHash: block.PreviousHash,
+		Transactions: FromTransactions(block.Transactions),
+		Timestamp:    block.Timestamp,
+		Signature:    fromSignature(block.Signature),
+	}
+}
+
+func ToBlock(block *Block) *blockchain.Block {
+	return &blockchain.Block{
+		ID:           block.GetId(),
+		Hash:         block.GetHash(),
+		PreviousHash: block.GetPreviousHash(),
+		Transactions: ToTransactions(block.GetTransactions()),
+		Timestamp:    block.GetTimestamp(),
+		Signature:    toSignature(block.GetSignature()),
+	}
+}
+
+func fromSignature(signature *blockchain.Signature) *Signature {
+	if signature == nil {
+		return nil
+	}
+	return &Signature{R: signature.R, S: signature.S}
+}
+
+func toSignature(signature *Signature) *blockchain.Signature {
+	if signature == nil {
+		return nil
+	}
+	return &blockchain.Signature{R: signature.GetR(), S: signature.GetS()}
+}
@@ -0,0 +1,56 @@
+package nodepb
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestSigner(t *testing.T) utils.Signer {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Failed to generate key")
+	return utils.NewMemorySigner(privKey)
+}
+
+// Ein über Protobuf übertragener Block muss Hash und Signaturen behalten
+func TestBlockRoundTripKeepsSignatures(t *testing.T) {
+	authority := newTestSigner(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	genesis, err := blockchain.CreateGenesisBlock("ega-test", authority)
+	require.NoError(t, err)
+
+	tx, err := blockchain.NewTransaction("ega-test", 0, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	urgent, err := blockchain.NewUrgentTransaction("ega-test", 1, "Allergy", "Penicillin", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+
+	block := &blockchain.Block{
+		ID:           1,
+		PreviousHash: genesis.Hash,
+		Transactions: []*blockchain.Transaction{tx, urgent},
+		Timestamp:    genesis.Timestamp + 1,
+	}
+	block.Hash, err = block.CalculateHash("ega-test")
+	require.NoError(t, err)
+	require.NoError(t, block.SignBlock(authority))
+
+	for _, original := range []*blockchain.Block{genesis, block} {
+		data, err := proto.Marshal(FromBlock(original))
+		require.NoError(t, err)
+
+		var message Block
+		require.NoError(t, proto.Unmarshal(data, &message))
+
+		decoded := ToBlock(&message)
+		require.Equal(t, original.ID, decoded.ID)
+		require.NoError(t, decoded.ValidateBlock(authority.PublicKey(), "ega-test"))
+	}
+}
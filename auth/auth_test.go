@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
+)
+
+const testChainID = "ega-test"
+
+func newTestSigner(t *testing.T) (utils.Signer, string) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer := utils.NewMemorySigner(privateKey)
+	return signer, blockchain.KeyID(utils.SerializePublicKey(signer.PublicKey()))
+}
+
+func newSignedRequest(t *testing.T, signer utils.Signer, chainID string, body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/webhooks?x=1", bytes.NewReader(body))
+	require.NoError(t, SignRequest(req, body, signer, chainID))
+	return req
+}
+
+func TestSignedRequestIsAuthenticatedOnce(t *testing.T) {
+	signer, keyID := newTestSigner(t)
+	authenticator := NewAuthenticator(testChainID, NewPolicy(nil))
+	body := []byte(`{"url":"https://example.org"}`)
+
+	req := newSignedRequest(t, signer, testChainID, body)
+	identity, err := authenticator.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, keyID, identity.KeyID)
+
+	// Der Handler muss den Body weiterhin lesen können
+	restored, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, body, restored)
+
+	// Dieselbe Anfrage ein zweites Mal ist ein Replay
+	replay := httptest.NewRequest(http.MethodPost, "/v1/webhooks?x=1", bytes.NewReader(body))
+	replay.Header.Set("Authorization", req.Header.Get("Authorization"))
+	_, err = authenticator.Authenticate(replay)
+	require.Error(t, err)
+}
+
+func TestSignedRequestRejectsTampering(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	authenticator := NewAuthenticator(testChainID, NewPolicy(nil))
+
+	// Geänderter Body
+	req := newSignedRequest(t, signer, testChainID, []byte("original"))
+	req.Body = io.NopCloser(bytes.NewReader([]byte("changed")))
+	_, err := authenticator.Authenticate(req)
+	require.Error(t, err)
+
+	// Signatur für eine andere Chain
+	req = newSignedRequest(t, signer, "other-chain", []byte("original"))
+	_, err = authenticator.Authenticate(req)
+	require.Error(t, err)
+}
+
+func TestChallengeLogin(t *testing.T) {
+	signer, keyID := newTestSigner(t)
+	other, _ := newTestSigner(t)
+	authenticator := NewAuthenticator(testChainID, NewPolicy(nil))
+
+	challenge, _, err := authenticator.IssueChallenge(keyID)
+	require.NoError(t, err)
+
+	// Ein fremder Schlüssel kann die Challenge nicht einlösen
+	forged, err := SignChallenge(other, testChainID, keyID, challenge)
+	require.NoError(t, err)
+	_, _, err = authenticator.RedeemChallenge(keyID, challenge, forged)
+	require.Error(t, err)
+
+	// Die Challenge ist nach dem Fehlversuch verbraucht
+	signature, err := SignChallenge(signer, testChainID, keyID, challenge)
+	require.NoError(t, err)
+	_, _, err = authenticator.RedeemChallenge(keyID, challenge, signature)
+	require.Error(t, err)
+
+	challenge, _, err = authenticator.IssueChallenge(keyID)
+	require.NoError(t, err)
+	signature, err = SignChallenge(signer, testChainID, keyID, challenge)
+	require.NoError(t, err)
+	token, _, err := authenticator.RedeemChallenge(keyID, challenge, signature)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/getPatientTransactions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	identity, err := authenticator.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, keyID, identity.KeyID)
+}
+
+func TestAuthorize(t *testing.T) {
+	operator, operatorID := newTestSigner(t)
+	patient, patientID := newTestSigner(t)
+	authenticator := NewAuthenticator(testChainID, NewPolicy(&RoleConfig{Operators: []string{operatorID}}))
+
+	authorize := func(req *http.Request, roles []Role, owners ...string) int {
+		recorder := httptest.NewRecorder()
+		if _, ok := authenticator.Authorize(recorder, req, roles, owners...); ok {
+			return http.StatusOK
+		}
+		return recorder.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, authorize(httptest.NewRequest(http.MethodGet, "/createBlock", nil), []Role{RoleOperator}))
+	require.Equal(t, http.StatusOK, authorize(newSignedRequest(t, operator, testChainID, nil), []Role{RoleOperator}))
+	require.Equal(t, http.StatusForbidden, authorize(newSignedRequest(t, patient, testChainID, nil), []Role{RoleOperator}))
+	require.Equal(t, http.StatusOK, authorize(newSignedRequest(t, patient, testChainID, nil), []Role{RoleAuditor}, patientID))
+
+	// Ein zu großer Body wird abgelehnt statt abgeschnitten signiert
+	require.Equal(t, http.StatusRequestEntityTooLarge, authorize(newSignedRequest(t, operator, testChainID, make([]byte, MaxRequestBodySize+1)), []Role{RoleOperator}))
+
+	// Ohne Authenticator ist alles erlaubt
+	var disabled *Authenticator
+	_, ok := disabled.Authorize(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/createBlock", nil), []Role{RoleOperator})
+	require.True(t, ok)
+}
+
+func TestIdentityFromClientCertificate(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client-node"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	authenticator := NewAuthenticator(testChainID, NewPolicy(nil))
+	req := httptest.NewRequest(http.MethodGet, "/sync", nil)
+
+	// Nur geprüfte Zertifikatsketten weisen den Aufrufer aus
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{certificate}}
+	identity, err := authenticator.Authenticate(req)
+	require.NoError(t, err)
+	require.Nil(t, identity)
+
+	req.TLS.VerifiedChains = [][]*x509.Certificate{{certificate}}
+	identity, err = authenticator.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, blockchain.KeyID(utils.SerializePublicKey(&privateKey.PublicKey)), identity.KeyID)
+}
+
+// Abgelaufene Challenges und Sessions werden beim Ausstellen neuer aufgeräumt
+func TestExpiredSessionsAreSwept(t *testing.T) {
+	signer, keyID := newTestSigner(t)
+	authenticator := NewAuthenticator(testChainID, NewPolicy(nil))
+
+	challenge, _, err := authenticator.IssueChallenge(keyID)
+	require.NoError(t, err)
+	signature, err := SignChallenge(signer, testChainID, keyID, challenge)
+	require.NoError(t, err)
+	_, _, err = authenticator.RedeemChallenge(keyID, challenge, signature)
+	require.NoError(t, err)
+	_, _, err = authenticator.IssueChallenge(keyID)
+	require.NoError(t, err)
+	require.Len(t, authenticator.sessions, 1)
+	require.Len(t, authenticator.challenges, 1)
+
+	// Später als SessionTTL: alles ist abgelaufen
+	authenticator.mutex.Lock()
+	for token, session := range authenticator.sessions {
+		session.ExpiresAt = time.Now().Add(-time.Second)
+		authenticator.sessions[token] = session
+	}
+	for key, pending := range authenticator.challenges {
+		pending.ExpiresAt = time.Now().Add(-time.Second)
+		authenticator.challenges[key] = pending
+	}
+	authenticator.lastSweep = time.Now().Add(-sweepInterval)
+	authenticator.mutex.Unlock()
+
+	_, _, err = authenticator.IssueChallenge(keyID)
+	require.NoError(t, err)
+	require.Empty(t, authenticator.sessions)
+	require.Len(t, authenticator.challenges, 1)
+}
+
+// Sind maxRememberedRequests Nonces noch gültig, werden weitere signierte Anfragen abgelehnt statt gemerkt
+func TestSignedRequestsAreLimited(t *testing.T) {
+	signer, _ := newTestSigner(t)
+	authenticator := NewAuthenticator(testChainID, NewPolicy(nil))
+
+	authenticator.mutex.Lock()
+	for i := range maxRememberedRequests {
+		authenticator.nonces[fmt.Sprintf("remembered/%d", i)] = time.Now().Add(MaxClockSkew)
+	}
+	authenticator.mutex.Unlock()
+
+	recorder := httptest.NewRecorder()
+	_, ok := authenticator.Authorize(recorder, newSignedRequest(t, signer, testChainID, nil), nil)
+	require.False(t, ok)
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+	require.Len(t, authenticator.nonces, maxRememberedRequests)
+
+	// Nach Ablauf der gemerkten Nonces ist wieder Platz
+	authenticator.mutex.Lock()
+	for key := range authenticator.nonces {
+		authenticator.nonces[key] = time.Now().Add(-time.Second)
+	}
+	authenticator.mutex.Unlock()
+
+	_, err := authenticator.Authenticate(newSignedRequest(t, signer, testChainID, nil))
+	require.NoError(t, err)
+	require.Len(t, authenticator.nonces, 1)
+}
@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+)
+
+const (
+	// MaxClockSkew ist die erlaubte Abweichung des Zeitstempels signierter Anfragen
+	MaxClockSkew = 5 * time.Minute
+	// ChallengeTTL ist die Gültigkeit einer Login-Challenge
+	ChallengeTTL = 2 * time.Minute
+	// SessionTTL ist die Gültigkeit eines Session-Tokens nach erfolgreichem Login
+	SessionTTL = 15 * time.Minute
+
+	// MaxRequestBodySize begrenzt den Body authentifizierter Anfragen, größere werden mit 413 abgelehnt
+	MaxRequestBodySize = 10 << 20
+
+	maxPendingChallenges  = 10000
+	maxSessions           = 10000
+	maxRememberedRequests = 100000
+	sweepInterval         = time.Minute
+)
+
+var (
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrForbidden       = errors.New("access denied")
+	ErrBodyTooLarge    = errors.New("request body too large")
+	// ErrTooManyRequests: es sind bereits maxRememberedRequests signierte Anfragen gültig, die Nonce
+	// könnte nicht gegen Replays gespeichert werden
+	ErrTooManyRequests = errors.New("too many signed requests, try again later")
+)
+
+// Identity ist der authentifizierte Aufrufer
+type Identity struct {
+	KeyID     string
+	PublicKey *ecdsa.PublicKey
+}
+
+// Authenticator prüft signierte Anfragen und Session-Tokens und entscheidet über den Zugriff.
+// Ein nil-Authenticator bedeutet, dass die Authentifizierung deaktiviert ist und alles erlaubt wird.
+type Authenticator struct {
+	ChainID string
+	Policy  *Policy
+
+	nonces     map[string]time.Time // bereits verwendete Nonces signierter Anfragen bis zum Ablauf
+	challenges map[string]pendingChallenge
+	sessions   map[string]session
+	lastSweep  time.Time
+	mutex      sync.Mutex
+}
+
+type pendingChallenge struct {
+	KeyID     string
+	ExpiresAt time.Time
+}
+
+type session struct {
+	Identity  Identity
+	ExpiresAt time.Time
+}
+
+func NewAuthenticator(chainID string, policy *Policy) *Authenticator {
+	return &Authenticator{
+		ChainID:    chainID,
+		Policy:     policy,
+		nonces:     make(map[string]time.Time),
+		challenges: make(map[string]pendingChallenge),
+		sessions:   make(map[string]session),
+	}
+}
+
+// Authenticate ermittelt den Aufrufer aus dem Authorization-Header oder, ohne Header, aus dem
+// geprüften Client-Zertifikat (mTLS). Ohne beides ist die Identität nil. Signierte Anfragen lesen den
+// Body, der Aufrufer begrenzt ihn wie Authorize mit http.MaxBytesReader.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return IdentityFromTLS(r.TLS), nil
+	}
+
+	scheme, credentials, _ := strings.Cut(header, " ")
+	switch scheme {
+	case SignatureScheme:
+		return a.authenticateSignedRequest(r, credentials)
+	case "Bearer":
+		return a.AuthenticateToken(strings.TrimSpace(credentials))
+	default:
+		return nil, fmt.Errorf("unsupported authorization scheme %q", scheme)
+	}
+}
+
+func (a *Authenticator) authenticateSignedRequest(r *http.Request, credentials string) (*Identity, error) {
+	signed, err := parseSignedRequest(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	requestTime := time.Unix(signed.Timestamp, 0)
+	if requestTime.Before(now.Add(-MaxClockSkew)) || requestTime.After(now.Add(MaxClockSkew)) {
+		return nil, fmt.Errorf("request timestamp outside allowed clock skew")
+	}
+
+	// Der Body wird für den Hash gelesen und für den Handler wiederhergestellt
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, tooLarge.Limit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %v", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	digest := requestDigest(a.ChainID, r.Method, r.URL.RequestURI(), signed.Timestamp, signed.Nonce, body)
+	publicKey, err := verifySignature(signed.KeyID, digest, signed.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("request signature invalid: %v", err)
+	}
+
+	if err := a.rememberNonce(signed.KeyID+"/"+signed.Nonce, requestTime.Add(MaxClockSkew)); err != nil {
+		return nil, err
+	}
+
+	return &Identity{KeyID: signed.KeyID, PublicKey: publicKey}, nil
+}
+
+// rememberNonce merkt sich eine Nonce bis zu ihrem Ablauf. Bereits verwendete Nonces werden abgelehnt,
+// ebenso neue, solange nach dem Aufräumen noch maxRememberedRequests Nonces gültig sind.
+func (a *Authenticator) rememberNonce(nonce string, expiresAt time.Time) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	a.sweep(now, len(a.nonces) >= maxRememberedRequests)
+
+	if expiry, used := a.nonces[nonce]; used && expiry.After(now) {
+		return fmt.Errorf("request was already used")
+	}
+	if len(a.nonces) >= maxRememberedRequests {
+		return ErrTooManyRequests
+	}
+	a.nonces[nonce] = expiresAt
+	return nil
+}
+
+// IdentityFromTLS liefert die Identität zum Schlüssel eines geprüften Client-Zertifikats. Das Zertifikat
+// muss auf den P-256-Schlüssel des Aufrufers ausgestellt sein, damit die KeyID in der Rollendatei passt.
+func IdentityFromTLS(state *tls.ConnectionState) *Identity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	publicKey, ok := state.VerifiedChains[0][0].PublicKey.(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return nil
+	}
+	return &Identity{KeyID: blockchain.KeyID(utils.SerializePublicKey(publicKey)), PublicKey: publicKey}
+}
+
+func (a *Authenticator) AuthenticateToken(token string) (*Identity, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	session, exists := a.sessions[token]
+	if !exists || time.Now().After(session.ExpiresAt) {
+		delete(a.sessions, token)
+		return nil, fmt.Errorf("invalid or expired session token")
+	}
+
+	identity := session.Identity
+	return &identity, nil
+}
+
+// IssueChallenge erzeugt eine Challenge, die der Inhaber des Schlüssels mit SignChallenge signiert
+func (a *Authenticator) IssueChallenge(keyID string) (string, time.Time, error) {
+	if _, err := PublicKeyFromKeyID(keyID); err != nil {
+		return "", time.Time{}, err
+	}
+
+	challenge, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ChallengeTTL)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.sweep(time.Now(), len(a.challenges) >= maxPendingChallenges)
+	if len(a.challenges) >= maxPendingChallenges {
+		return "", time.Time{}, fmt.Errorf("too many pending challenges")
+	}
+
+	a.challenges[challenge] = pendingChallenge{KeyID: keyID, ExpiresAt: expiresAt}
+	return challenge, expiresAt, nil
+}
+
+// RedeemChallenge prüft die signierte Challenge und gibt ein Session-Token für den Schlüssel aus.
+// Jede Challenge kann nur einmal eingelöst werden.
+func (a *Authenticator) RedeemChallenge(keyID, challenge string, signature []byte) (string, time.Time, error) {
+	a.mutex.Lock()
+	pending, exists := a.challenges[challenge]
+	delete(a.challenges, challenge)
+	a.mutex.Unlock()
+
+	if !exists || pending.KeyID != keyID || time.Now().After(pending.ExpiresAt) {
+		return "", time.Time{}, fmt.Errorf("unknown or expired challenge")
+	}
+
+	publicKey, err := verifySignature(keyID, challengeDigest(a.ChainID, keyID, challenge), signature)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("challenge signature invalid: %v", err)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(SessionTTL)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.sweep(time.Now(), len(a.sessions) >= maxSessions)
+	if len(a.sessions) >= maxSessions {
+		return "", time.Time{}, fmt.Errorf("too many active sessions")
+	}
+	a.sessions[token] = session{Identity: Identity{KeyID: keyID, PublicKey: publicKey}, ExpiresAt: expiresAt}
+
+	return token, expiresAt, nil
+}
+
+// sweep entfernt abgelaufene Challenges, Sessions und Nonces, höchstens einmal pro sweepInterval oder
+// sofort mit force (z.B. wenn eine Obergrenze erreicht ist). Der Aufrufer muss den Mutex halten.
+func (a *Authenticator) sweep(now time.Time, force bool) {
+	if !force && now.Sub(a.lastSweep) < sweepInterval {
+		return
+	}
+	a.lastSweep = now
+
+	for key, pending := range a.challenges {
+		if now.After(pending.ExpiresAt) {
+			delete(a.challenges, key)
+		}
+	}
+	for token, session := range a.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(a.sessions, token)
+		}
+	}
+	for key, expiry := range a.nonces {
+		if now.After(expiry) {
+			delete(a.nonces, key)
+		}
+	}
+}
+
+// SignChallenge beweist den Besitz des Schlüssels für eine Login-Challenge
+func SignChallenge(signer utils.Signer, chainID, keyID, challenge string) ([]byte, error) {
+	r, s, err := utils.SignDigest(signer, challengeDigest(chainID, keyID, challenge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign challenge: %v", err)
+	}
+	return utils.EncodeSignature(r, s)
+}
+
+func challengeDigest(chainID, keyID, challenge string) []byte {
+	return utils.DomainHash(utils.DomainChallenge, chainID, []byte(keyID+"\n"+challenge))
+}
+
+// Allowed prüft, ob die Identität eine der Rollen hat oder einer der Eigentümer (KeyIDs) ist
+func (a *Authenticator) Allowed(identity *Identity, roles []Role, owners ...string) bool {
+	if a == nil {
+		return true
+	}
+	if identity == nil {
+		return false
+	}
+
+	for _, owner := range owners {
+		if owner != "" && identity.KeyID == owner {
+			return true
+		}
+	}
+	for _, role := range roles {
+		// Jeder authentifizierte Schlüssel ist implizit Patient
+		if role == RolePatient || a.Policy.Has(identity.KeyID, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize authentifiziert die Anfrage und prüft den Zugriff. Bei Ablehnung wird 401 bzw. 403
+// geschrieben (413 bei zu großem Body, 503 bei zu vielen gültigen signierten Anfragen) und false
+// zurückgegeben. Ohne Authenticator ist jeder Zugriff erlaubt (Identität nil). Pro Anfrage darf nur
+// einmal authentifiziert werden, da die Nonce signierter Anfragen verbraucht wird.
+func (a *Authenticator) Authorize(w http.ResponseWriter, r *http.Request, roles []Role, owners ...string) (*Identity, bool) {
+	if a == nil {
+		return nil, true
+	}
+
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodySize)
+	}
+	identity, err := a.Authenticate(r)
+	if err == nil && identity == nil {
+		err = ErrUnauthenticated
+	}
+	if errors.Is(err, ErrBodyTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if errors.Is(err, ErrTooManyRequests) {
+		// Abgelaufene Nonces werden beim nächsten Versuch aufgeräumt
+		w.Header().Set("Retry-After", strconv.Itoa(int(sweepInterval.Seconds())))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", SignatureScheme)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if !a.Allowed(identity, roles, owners...) {
+		http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	return identity, true
+}
+
+type identityContextKey struct{}
+
+func ContextWithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityContextKey{}).(*Identity)
+	return identity
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Role ist eine Berechtigungsstufe. Patienten werden nicht registriert: jeder authentifizierte
+// Schlüssel darf als Patient auf seine eigenen Einträge zugreifen.
+type Role string
+
+const (
+	RoleOperator  Role = "operator"  // Betrieb des Authority Nodes: Blockerstellung, Pool, Reindex, alle Webhooks
+	RoleDoctor    Role = "doctor"    // darf Transaktionen einreichen
+	RoleEmergency Role = "emergency" // Arzt, der zusätzlich dringende Einträge einreichen darf
+	RoleAuditor   Role = "auditor"   // Lesezugriff auf die gesamte Blockchain, auch für replizierende Client Nodes
+	RolePatient   Role = "patient"   // implizit, nur Zugriff auf eigene Einträge
+)
+
+// RoleConfig ist das Dateiformat der Rollenzuordnung, die Einträge sind KeyIDs (siehe "key id")
+type RoleConfig struct {
+	Operators []string `json:"operators"`
+	Doctors   []string `json:"doctors"`
+	Emergency []string `json:"emergency"` // Ärzte, die dringende Einträge einreichen dürfen (zusätzlich zu doctors)
+	Auditors  []string `json:"auditors"`
+}
+
+func LoadRoleConfig(path string) (*RoleConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roles: %v", err)
+	}
+
+	var config RoleConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode roles: %v", err)
+	}
+	return &config, nil
+}
+
+// Policy ordnet KeyIDs ihre Rollen zu
+type Policy struct {
+	members map[Role]map[string]bool
+}
+
+func NewPolicy(config *RoleConfig) *Policy {
+	p := &Policy{members: make(map[Role]map[string]bool)}
+	if config == nil {
+		return p
+	}
+
+	for _, keyID := range config.Operators {
+		p.Grant(RoleOperator, keyID)
+	}
+	for _, keyID := range config.Doctors {
+		p.Grant(RoleDoctor, keyID)
+	}
+	for _, keyID := range config.Emergency {
+		p.Grant(RoleEmergency, keyID)
+	}
+	for _, keyID := range config.Auditors {
+		p.Grant(RoleAuditor, keyID)
+	}
+	return p
+}
+
+func (p *Policy) Grant(role Role, keyID string) {
+	if p.members[role] == nil {
+		p.members[role] = make(map[string]bool)
+	}
+	p.members[role][keyID] = true
+}
+
+func (p *Policy) Has(keyID string, role Role) bool {
+	return p.members[role][keyID]
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+)
+
+// SignatureScheme ist das Schema im Authorization-Header signierter Anfragen:
+//
+//	Authorization: EGA-ECDSA keyId=<KeyID>, timestamp=<unix>, nonce=<hex>, signature=<base64url R||S>
+const SignatureScheme = "EGA-ECDSA"
+
+// signedRequest sind die Felder eines EGA-ECDSA-Headers
+type signedRequest struct {
+	KeyID     string
+	Timestamp int64
+	Nonce     string
+	Signature []byte
+}
+
+// SignRequest signiert Methode, Pfad mit Query, Zeitstempel, Nonce und den Hash des Bodys.
+// body muss dem Inhalt von req.Body entsprechen.
+func SignRequest(req *http.Request, body []byte, signer utils.Signer, chainID string) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate request nonce: %v", err)
+	}
+
+	signed := signedRequest{
+		KeyID:     blockchain.KeyID(utils.SerializePublicKey(signer.PublicKey())),
+		Timestamp: time.Now().Unix(),
+		Nonce:     hex.EncodeToString(nonce),
+	}
+
+	digest := requestDigest(chainID, req.Method, req.URL.RequestURI(), signed.Timestamp, signed.Nonce, body)
+	r, s, err := utils.SignDigest(signer, digest)
+	if err != nil {
+		return fmt.Errorf("failed to sign request: %v", err)
+	}
+	if signed.Signature, err = utils.EncodeSignature(r, s); err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s keyId=%s, timestamp=%d, nonce=%s, signature=%s",
+		SignatureScheme, signed.KeyID, signed.Timestamp, signed.Nonce, base64.RawURLEncoding.EncodeToString(signed.Signature)))
+	return nil
+}
+
+func requestDigest(chainID, method, requestURI string, timestamp int64, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	payload := strings.Join([]string{
+		method,
+		requestURI,
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	return utils.DomainHash(utils.DomainRequest, chainID, []byte(payload))
+}
+
+func parseSignedRequest(credentials string) (*signedRequest, error) {
+	var signed signedRequest
+	for _, part := range strings.Split(credentials, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return nil, fmt.Errorf("malformed authorization header")
+		}
+
+		switch key {
+		case "keyId":
+			signed.KeyID = value
+		case "timestamp":
+			timestamp, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp in authorization header")
+			}
+			signed.Timestamp = timestamp
+		case "nonce":
+			signed.Nonce = value
+		case "signature":
+			signature, err := base64.RawURLEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid signature encoding in authorization header")
+			}
+			signed.Signature = signature
+		}
+	}
+
+	if signed.KeyID == "" || signed.Timestamp == 0 || signed.Nonce == "" || signed.Signature == nil {
+		return nil, fmt.Errorf("incomplete authorization header")
+	}
+	return &signed, nil
+}
+
+// PublicKeyFromKeyID stellt den Public Key aus einer KeyID wieder her
+func PublicKeyFromKeyID(keyID string) (*ecdsa.PublicKey, error) {
+	publicKeyBytes, err := base64.URLEncoding.DecodeString(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key ID: %v", err)
+	}
+	return utils.DeserializePublicKey(publicKeyBytes)
+}
+
+// verifySignature prüft eine kanonische R||S-Signatur über den Digest mit dem Schlüssel der KeyID
+func verifySignature(keyID string, digest, signature []byte) (*ecdsa.PublicKey, error) {
+	publicKey, err := PublicKeyFromKeyID(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := utils.DecodeSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.VerifyDigest(publicKey, digest, r, s) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	return publicKey, nil
+}
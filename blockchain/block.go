@@ -1,8 +1,8 @@
 package blockchain
 
 import (
+	"bytes"
 	"crypto/ecdsa"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 
@@ -18,7 +18,29 @@ type Block struct {
 	Signature    *Signature `json:"signature"`
 }
 
-func (b *Block) CalculateHash() ([]byte, error) {
+// BlockHeader enthält die Metadaten eines Blocks ohne die Transaktionen. Anders als Block, dessen
+// Feldnamen in den Block-Hash eingehen, hat der Header ein eigenes Wire-Format in camelCase.
+type BlockHeader struct {
+	ID               uint64     `json:"id"`
+	Hash             []byte     `json:"hash"`
+	PreviousHash     []byte     `json:"previousHash"`
+	Timestamp        int64      `json:"timestamp"`
+	TransactionCount int        `json:"transactionCount"`
+	Signature        *Signature `json:"signature"`
+}
+
+func (b *Block) Header() *BlockHeader {
+	return &BlockHeader{
+		ID:               b.ID,
+		Hash:             b.Hash,
+		PreviousHash:     b.PreviousHash,
+		Timestamp:        b.Timestamp,
+		TransactionCount: len(b.Transactions),
+		Signature:        b.Signature,
+	}
+}
+
+func (b *Block) CalculateHash(chainID string) ([]byte, error) {
 	// Erstelle eine temporäre Kopie des Blocks ohne Hash und Signatur
 	tempBlock := *b
 	tempBlock.Hash = nil
@@ -29,16 +51,15 @@ func (b *Block) CalculateHash() ([]byte, error) {
 		return nil, fmt.Errorf("failed to serialize block: %v", err)
 	}
 
-	// Berechne den Hash aus den Blockdaten
-	hash := sha256.Sum256(blockBytes)
-	return hash[:], nil
+	// Berechne den domänengetrennten Hash aus den Blockdaten
+	return utils.DomainHash(utils.DomainBlockHeader, chainID, blockBytes), nil
 }
 
-func (b *Block) SignBlock(privateKey *ecdsa.PrivateKey) error {
-	// Signiere den bereits berechneten Hash der Transaktion
-	r, s, err := utils.SignTransaction(privateKey, b.Hash)
+func (b *Block) SignBlock(signer utils.Signer) error {
+	// Signiere den bereits berechneten Hash des Blocks
+	r, s, err := utils.SignDigest(signer, b.Hash)
 	if err != nil {
-		return fmt.Errorf("failed to generate transaction signature: %v", err)
+		return fmt.Errorf("failed to generate block signature: %v", err)
 	}
 
 	b.Signature = &Signature{
@@ -48,3 +69,55 @@ func (b *Block) SignBlock(privateKey *ecdsa.PrivateKey) error {
 
 	return nil
 }
+
+// ValidateBlock prüft Hash und Signatur des Blocks sowie alle enthaltenen Transaktionen
+func (b *Block) ValidateBlock(publicKey *ecdsa.PublicKey, chainID string) error {
+	if err := b.validateHash(chainID); err != nil {
+		return err
+	}
+
+	// Überprüfe, ob die Signatur gültig ist
+	if b.Signature == nil {
+		return fmt.Errorf("missing signature for block ID %d", b.ID)
+	}
+	if !utils.VerifyDigest(publicKey, b.Hash, b.Signature.R, b.Signature.S) {
+		return fmt.Errorf("invalid signature for block hash %x", b.Hash)
+	}
+
+	return b.validateTransactions(chainID)
+}
+
+// ValidateContent prüft Hash und Transaktionen, aber nicht die Signatur, z.B. solange der Schlüssel der Authority unbekannt ist
+func (b *Block) ValidateContent(chainID string) error {
+	if err := b.validateHash(chainID); err != nil {
+		return err
+	}
+	return b.validateTransactions(chainID)
+}
+
+func (b *Block) validateHash(chainID string) error {
+	hash, err := b.CalculateHash(chainID)
+	if err != nil {
+		return err
+	}
+
+	// Überprüfe, ob der berechnete Hash mit dem gespeicherten Hash übereinstimmt
+	if !bytes.Equal(hash, b.Hash) {
+		return fmt.Errorf("invalid block hash for block ID %d", b.ID)
+	}
+	return nil
+}
+
+func (b *Block) validateTransactions(chainID string) error {
+	for _, tx := range b.Transactions {
+		doctorPublicKey, err := utils.DeserializePublicKey(tx.Doctor)
+		if err != nil {
+			return fmt.Errorf("couldn't deserialize doctor public key: %v", err)
+		}
+		if err := tx.ValidateTransaction(doctorPublicKey, chainID); err != nil {
+			return fmt.Errorf("invalid transaction in block ID %d: %v", b.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -1,39 +1,384 @@
 package blockchain
 
 import (
+	"bytes"
 	"crypto/ecdsa"
-	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
 )
 
-// Blockchain represents the structure of the blockchain containing all blocks and a map for quick lookup
+// DefaultChainID wird verwendet, wenn keine Chain-ID konfiguriert ist
+const DefaultChainID = "ega-local"
+
+// Blockchain represents the structure of the blockchain containing all blocks and a map for quick lookup.
+// Sobald die Blockchain von mehreren Goroutinen verwendet wird, nur noch über die Methoden zugreifen:
+// Schreibende Methoden sperren exklusiv, lesende liefern einen konsistenten Stand.
 type Blockchain struct {
-	Blocks   []*Block          // Liste aller Blöcke in der Blockchain
-	BlockMap map[string]*Block // Mapping von Block-Hash zu Block, um schnellen Zugriff zu ermöglichen
+	ChainID      string            // Chain-ID, fließt in alle Block- und Transaktionssignaturen ein
+	Blocks       []*Block          // Liste aller Blöcke in der Blockchain
+	BlockMap     map[string]*Block // Mapping von Block-Hash zu Block, um schnellen Zugriff zu ermöglichen
+	DoctorNonces map[string]uint64 // Nächste erwartete Nonce je Arzt (KeyID), bereits enthaltene Nonces sind verbraucht
+	Indexer      *Indexer          // Abgeleitete Indizes für Transaktionen, Patienten und Ärzte
+	Store        *BlockStore       // Optionaler persistenter Speicher, nil für eine reine In-Memory-Blockchain
+
+	mutex        sync.RWMutex  // schützt Blocks, BlockMap, DoctorNonces und den Indexer-Zeiger
+	changed      chan struct{} // wird geschlossen, sobald ein neuer Block hinzugefügt wurde
+	changedMutex sync.Mutex
+}
+
+// NewEmptyBlockchain erstellt eine Blockchain ohne Genesis-Block, z.B. für Client Nodes vor der Synchronisierung
+func NewEmptyBlockchain(chainID string) *Blockchain {
+	return &Blockchain{
+		ChainID:      chainID,
+		Blocks:       []*Block{},
+		BlockMap:     make(map[string]*Block),
+		DoctorNonces: make(map[string]uint64),
+		Indexer:      NewIndexer(),
+	}
+}
+
+// LoadBlockchain lädt die Blöcke aus dem Store und prüft jeden wie einen empfangenen Block: Hash,
+// Verkettung mit dem Vorgänger, Transaktionen und, falls Authority-Schlüssel angegeben sind, die Signatur.
+// Der gespeicherte Transaktionsindex wird weiterverwendet und nur um fehlende Blöcke ergänzt, ein
+// inkonsistenter Index wird neu aufgebaut.
+func LoadBlockchain(chainID string, store *BlockStore, authorities []*ecdsa.PublicKey) (*Blockchain, error) {
+	blocks, err := store.LoadBlocks()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := store.LoadTxIndex()
+	if err == nil {
+		err = index.Verify(blocks)
+	}
+	if err != nil {
+		fmt.Printf("Stored tx index is invalid, rebuilding: %v\n", err)
+		if err := store.RewriteTxIndex(nil); err != nil {
+			return nil, err
+		}
+		index = NewTxIndex()
+	}
+
+	// Patienten- und Arztindex liegen nur im Speicher und werden beim Abspielen der Blöcke aufgebaut
+	bc := NewEmptyBlockchain(chainID)
+	bc.Indexer = newIndexerWithTxIndex(index)
+	bc.Store = store
+
+	for _, block := range blocks {
+		if err := VerifyBlock(block, chainID, authorities); err != nil {
+			return nil, fmt.Errorf("stored block %d is invalid: %v", block.ID, err)
+		}
+		if err := bc.appendBlock(block, false); err != nil {
+			return nil, fmt.Errorf("stored block %d is invalid: %v", block.ID, err)
+		}
+	}
+
+	return bc, nil
+}
+
+// VerifyBlock prüft den Block gegen die Authority-Schlüssel, er muss von einem davon signiert sein.
+// Ohne Schlüssel werden nur Hash und Transaktionen geprüft.
+func VerifyBlock(block *Block, chainID string, authorities []*ecdsa.PublicKey) error {
+	if len(authorities) == 0 {
+		return block.ValidateContent(chainID)
+	}
+
+	var err error
+	for _, publicKey := range authorities {
+		if err = block.ValidateBlock(publicKey, chainID); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Verify prüft alle Blöcke gegen die Authority-Schlüssel, z.B. nachdem ein Client Node den Schlüssel
+// der Authority erst nach dem Laden übernommen hat
+func (bc *Blockchain) Verify(authorities []*ecdsa.PublicKey) error {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	for _, block := range bc.Blocks {
+		if err := VerifyBlock(block, bc.ChainID, authorities); err != nil {
+			return fmt.Errorf("block %d: %v", block.ID, err)
+		}
+	}
+	return nil
+}
+
+// KeyID kodiert einen serialisierten Public Key als URL-sicheres Base64, wie es für Arzt- und Patienten-IDs verwendet wird
+func KeyID(publicKey []byte) string {
+	return base64.URLEncoding.EncodeToString(publicKey)
 }
 
 // NewBlockchain creates a new blockchain with a genesis block
-func NewBlockchain(privateKey *ecdsa.PrivateKey) *Blockchain {
+func NewBlockchain(chainID string, signer utils.Signer) *Blockchain {
 	// Erstelle den Genesis-Block und initialisiere die Blockchain
-	genesisBlock, err := CreateGenesisBlock(privateKey)
+	genesisBlock, err := CreateGenesisBlock(chainID, signer)
 	if err != nil {
 		fmt.Printf("Failed to create genesis block: %v\n", err)
 		return nil
 	}
 
-	blockchain := &Blockchain{
-		Blocks:   []*Block{genesisBlock},
-		BlockMap: map[string]*Block{hex.EncodeToString(genesisBlock.Hash): genesisBlock},
+	blockchain := NewEmptyBlockchain(chainID)
+	if err := blockchain.AddBlock(genesisBlock); err != nil {
+		fmt.Printf("Failed to add genesis block: %v\n", err)
+		return nil
 	}
 
 	return blockchain
 }
 
+// NextNonce liefert die nächste Nonce, die für den Arzt in einen Block aufgenommen werden darf
+func (bc *Blockchain) NextNonce(doctor []byte) uint64 {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.DoctorNonces[KeyID(doctor)]
+}
+
+// AddBlock hängt einen bereits validierten Block an und speichert ihn, falls ein Store gesetzt ist.
+// Die Nonces der enthaltenen Transaktionen müssen je Arzt lückenlos an die bisherigen anschließen.
+func (bc *Blockchain) AddBlock(block *Block) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.appendBlock(block, true)
+}
+
+// Der Aufrufer muss den Mutex halten oder die Blockchain darf noch nicht geteilt sein (Laden)
+func (bc *Blockchain) appendBlock(block *Block, persist bool) error {
+	if block.ID != uint64(len(bc.Blocks)) {
+		return fmt.Errorf("block has ID %d, expected %d", block.ID, len(bc.Blocks))
+	}
+	if len(bc.Blocks) > 0 && !bytes.Equal(block.PreviousHash, bc.Blocks[len(bc.Blocks)-1].Hash) {
+		return fmt.Errorf("block %d does not extend the current tip", block.ID)
+	}
+
+	nextNonces := make(map[string]uint64)
+	for _, tx := range block.Transactions {
+		doctorID := KeyID(tx.Doctor)
+		expected, ok := nextNonces[doctorID]
+		if !ok {
+			expected = bc.DoctorNonces[doctorID]
+		}
+		if tx.Nonce != expected {
+			return fmt.Errorf("transaction %x has nonce %d, expected %d", tx.Hash, tx.Nonce, expected)
+		}
+		nextNonces[doctorID] = expected + 1
+	}
+
+	// Erst Block und Index speichern, dann den Speicher ändern: schlägt das Schreiben fehl, bleibt
+	// die Blockchain unverändert und der Block kann erneut angehängt werden
+	if persist && bc.Store != nil {
+		if err := bc.Store.Append(block, TxLocations(block)); err != nil {
+			return fmt.Errorf("failed to persist block: %v", err)
+		}
+	}
+
+	bc.Blocks = append(bc.Blocks, block)
+	bc.BlockMap[hex.EncodeToString(block.Hash)] = block
+	for doctorID, nonce := range nextNonces {
+		bc.DoctorNonces[doctorID] = nonce
+	}
+
+	// Beim Laden werden nur die im gespeicherten Index fehlenden Einträge nachgetragen
+	if entries := bc.Indexer.IndexBlock(block); !persist && entries != nil && bc.Store != nil {
+		if err := bc.Store.AppendTxIndex(block.ID, entries); err != nil {
+			return fmt.Errorf("failed to persist tx index: %v", err)
+		}
+	}
+
+	bc.notifyChanged()
+	return nil
+}
+
+// Close schließt den Store, ohne Store ist nichts zu tun
+func (bc *Blockchain) Close() error {
+	if bc.Store == nil {
+		return nil
+	}
+	return bc.Store.Close()
+}
+
+// Changed liefert einen Kanal, der geschlossen wird, sobald der nächste Block hinzugefügt wurde.
+// Der Kanal muss vor dem Lesen der Blöcke geholt werden, damit kein Block verpasst wird.
+func (bc *Blockchain) Changed() <-chan struct{} {
+	bc.changedMutex.Lock()
+	defer bc.changedMutex.Unlock()
+
+	if bc.changed == nil {
+		bc.changed = make(chan struct{})
+	}
+	return bc.changed
+}
+
+func (bc *Blockchain) notifyChanged() {
+	bc.changedMutex.Lock()
+	defer bc.changedMutex.Unlock()
+
+	if bc.changed != nil {
+		close(bc.changed)
+		bc.changed = nil
+	}
+}
+
+// Reindex baut alle Indizes durch erneutes Abspielen der Blöcke neu auf und ersetzt den gespeicherten Transaktionsindex
+func (bc *Blockchain) Reindex() error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	indexer := NewIndexer()
+	for _, block := range bc.Blocks {
+		indexer.IndexBlock(block)
+	}
+
+	if bc.Store != nil {
+		if err := bc.Store.RewriteTxIndex(bc.Blocks); err != nil {
+			return fmt.Errorf("failed to rewrite tx index: %v", err)
+		}
+	}
+
+	bc.Indexer = indexer
+	return nil
+}
+
+// GetBlockByID liefert den Block mit der angegebenen ID, die IDs entsprechen der Position in der Kette
+func (bc *Blockchain) GetBlockByID(id uint64) (*Block, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if id >= uint64(len(bc.Blocks)) {
+		return nil, false
+	}
+	return bc.Blocks[id], true
+}
+
+// GetBlockByHash liefert den Block mit dem angegebenen Hash (hex)
+func (bc *Blockchain) GetBlockByHash(blockHash string) (*Block, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	block, exists := bc.BlockMap[blockHash]
+	return block, exists
+}
+
+// LatestBlock liefert den letzten Block oder nil, wenn die Blockchain leer ist
+func (bc *Blockchain) LatestBlock() *Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if len(bc.Blocks) == 0 {
+		return nil
+	}
+	return bc.Blocks[len(bc.Blocks)-1]
+}
+
+// BlockRange liefert höchstens limit Blöcke ab der ID from
+func (bc *Blockchain) BlockRange(from uint64, limit int) []*Block {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	if from >= uint64(len(bc.Blocks)) {
+		return []*Block{}
+	}
+
+	to := from + uint64(limit)
+	if to > uint64(len(bc.Blocks)) {
+		to = uint64(len(bc.Blocks))
+	}
+	return append([]*Block(nil), bc.Blocks[from:to]...)
+}
+
+// Len liefert die Anzahl der Blöcke
+func (bc *Blockchain) Len() int {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return len(bc.Blocks)
+}
+
+// BlocksSince liefert alle Blöcke nach dem Block mit dem angegebenen Hash (hex), bei leerem Hash alle.
+// ok ist false, wenn der Block unbekannt ist.
+func (bc *Blockchain) BlocksSince(lastBlockHash string) (blocks []*Block, ok bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	start := 0
+	if lastBlockHash != "" {
+		block, exists := bc.BlockMap[lastBlockHash]
+		if !exists {
+			return nil, false
+		}
+		start = int(block.ID) + 1
+	}
+	return append([]*Block(nil), bc.Blocks[start:]...), true
+}
+
+// Stats zählt Blöcke und indizierte Einträge
+type Stats struct {
+	Blocks       int
+	Transactions int
+	Patients     int
+	Doctors      int
+}
+
+// Stats liefert die Zahlen eines konsistenten Stands von Blöcken und Index
+func (bc *Blockchain) Stats() Stats {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	stats := bc.Indexer.Stats()
+	stats.Blocks = len(bc.Blocks)
+	return stats
+}
+
+// PatientTransactions liefert alle Transaktionen eines Patienten in Blockreihenfolge
+func (bc *Blockchain) PatientTransactions(patientID string) []*Transaction {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.transactionsByHash(bc.Indexer.PatientTxHashes(patientID))
+}
+
+// DoctorTransactions liefert alle Transaktionen eines Arztes in Blockreihenfolge
+func (bc *Blockchain) DoctorTransactions(doctorID string) []*Transaction {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.transactionsByHash(bc.Indexer.DoctorTxHashes(doctorID))
+}
+
+// Der Aufrufer muss den Mutex halten
+func (bc *Blockchain) transactionsByHash(txHashes []string) []*Transaction {
+	transactions := make([]*Transaction, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		if tx, _, _, exists := bc.getTransaction(txHash); exists {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions
+}
+
+// GetTransaction sucht eine bereits in einen Block aufgenommene Transaktion über den Index
+func (bc *Blockchain) GetTransaction(txHash string) (*Transaction, *Block, TxLocation, bool) {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	return bc.getTransaction(txHash)
+}
+
+func (bc *Blockchain) getTransaction(txHash string) (*Transaction, *Block, TxLocation, bool) {
+	location, exists := bc.Indexer.Lookup(txHash)
+	if !exists || location.BlockID >= uint64(len(bc.Blocks)) {
+		return nil, nil, TxLocation{}, false
+	}
+
+	block := bc.Blocks[location.BlockID]
+	return block.Transactions[location.Position], block, location, true
+}
+
 // CreateGenesisBlock creates the initial block of the blockchain
-func CreateGenesisBlock(authorityPrivateKey *ecdsa.PrivateKey) (*Block, error) {
+func CreateGenesisBlock(chainID string, authoritySigner utils.Signer) (*Block, error) {
 	genesisBlock := &Block{
 		ID:           0,
 		PreviousHash: nil,
@@ -41,16 +386,16 @@ func CreateGenesisBlock(authorityPrivateKey *ecdsa.PrivateKey) (*Block, error) {
 		Timestamp:    time.Now().Unix(),
 	}
 
-	blockBytes, err := json.Marshal(genesisBlock)
+	hash, err := genesisBlock.CalculateHash(chainID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to serialize genesis block: %v", err)
+		return nil, fmt.Errorf("failed to hash genesis block: %v", err)
 	}
-
-	hash := sha256.Sum256(blockBytes)
-	genesisBlock.Hash = hash[:]
+	genesisBlock.Hash = hash
 
 	// 4. Signiere den Genesis-Block mit dem Private Key des Authority Nodes
-	genesisBlock.SignBlock(authorityPrivateKey)
+	if err := genesisBlock.SignBlock(authoritySigner); err != nil {
+		return nil, err
+	}
 
 	fmt.Println("Genesis Block created with ID 0 and hash:", genesisBlock.Hash)
 	return genesisBlock, nil
@@ -0,0 +1,110 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddBlockRejectsReplayedNonces(t *testing.T) {
+	authority := newTestSigner(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	bc := NewBlockchain("ega-test", authority)
+	require.NotNil(t, bc)
+
+	tx0, err := NewTransaction("ega-test", 0, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	tx1, err := NewTransaction("ega-test", 1, "Checkup", "Follow-up", "All normal", doctor, patient.PublicKey())
+	require.NoError(t, err)
+
+	genesis := bc.Blocks[0]
+
+	// Lücke in den Nonces wird abgelehnt
+	require.Error(t, bc.AddBlock(&Block{ID: 1, PreviousHash: genesis.Hash, Transactions: []*Transaction{tx1}}))
+
+	block := &Block{ID: 1, PreviousHash: genesis.Hash, Hash: []byte{1}, Transactions: []*Transaction{tx0, tx1}}
+	require.NoError(t, bc.AddBlock(block))
+	require.Equal(t, uint64(2), bc.NextNonce(tx0.Doctor))
+
+	// Dieselbe signierte Transaktion kann nicht erneut aufgenommen werden
+	require.Error(t, bc.AddBlock(&Block{ID: 2, PreviousHash: block.Hash, Transactions: []*Transaction{tx0}}))
+}
+
+// Mit -race ausführen: Abfragen lesen parallel zum Anhängen und Neuindizieren konsistente Stände
+func TestConcurrentAddBlockAndQueries(t *testing.T) {
+	authority := newTestSigner(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	bc := NewBlockchain("ega-test", authority)
+	require.NotNil(t, bc)
+
+	const blocks = 30
+	transactions := make([]*Transaction, blocks)
+	for nonce := range transactions {
+		tx, err := NewTransaction("ega-test", uint64(nonce), "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+		require.NoError(t, err)
+		transactions[nonce] = tx
+	}
+	patientID := KeyID(transactions[0].Patient)
+	genesis, _ := bc.GetBlockByID(0)
+
+	done := make(chan struct{})
+	var inconsistent atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				latest := bc.LatestBlock()
+				if _, exists := bc.GetBlockByHash(hex.EncodeToString(latest.Hash)); !exists {
+					inconsistent.Add(1)
+				}
+				since, ok := bc.BlocksSince(hex.EncodeToString(genesis.Hash))
+				if !ok || len(since) < int(latest.ID) {
+					inconsistent.Add(1)
+				}
+				for _, tx := range bc.PatientTransactions(patientID) {
+					if _, _, _, exists := bc.GetTransaction(hex.EncodeToString(tx.Hash)); !exists {
+						inconsistent.Add(1)
+					}
+				}
+				if stats := bc.Stats(); stats.Transactions != stats.Blocks-1 {
+					inconsistent.Add(1)
+				}
+				bc.BlockRange(0, 10)
+				bc.NextNonce(transactions[0].Doctor)
+			}
+		}()
+	}
+
+	for i, tx := range transactions {
+		latest := bc.LatestBlock()
+		block := &Block{ID: latest.ID + 1, PreviousHash: latest.Hash, Transactions: []*Transaction{tx}}
+		hash, err := block.CalculateHash("ega-test")
+		require.NoError(t, err)
+		block.Hash = hash
+		require.NoError(t, bc.AddBlock(block))
+		if i%10 == 0 {
+			require.NoError(t, bc.Reindex())
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	require.Zero(t, inconsistent.Load())
+	require.Len(t, bc.PatientTransactions(patientID), blocks)
+	require.Equal(t, blocks+1, bc.Stats().Blocks)
+}
@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"sync"
+)
+
+// Indexer hält die aus den Blöcken abgeleiteten Indizes. Er wird bei jedem angehängten Block
+// inkrementell fortgeschrieben und kann jederzeit durch erneutes Abspielen der Blöcke neu aufgebaut werden.
+// Lesende Methoden liefern Kopien und dürfen parallel zu IndexBlock aufgerufen werden.
+type Indexer struct {
+	TxIndex  *TxIndex            // Transaktions-Hash zu Block und Position (persistiert, falls ein Store gesetzt ist)
+	Patients map[string][]string // Patienten-ID (KeyID) zu Transaktions-Hashes (hex) in Blockreihenfolge
+	Doctors  map[string][]string // Arzt-ID (KeyID) zu Transaktions-Hashes (hex) in Blockreihenfolge
+
+	mutex sync.RWMutex
+}
+
+func NewIndexer() *Indexer {
+	return newIndexerWithTxIndex(NewTxIndex())
+}
+
+func newIndexerWithTxIndex(txIndex *TxIndex) *Indexer {
+	return &Indexer{
+		TxIndex:  txIndex,
+		Patients: make(map[string][]string),
+		Doctors:  make(map[string][]string),
+	}
+}
+
+// IndexBlock nimmt den Block in alle Indizes auf. Zurückgegeben werden nur die neuen
+// Einträge des Transaktionsindex; Blöcke, die dieser bereits kennt, liefern nil.
+func (ix *Indexer) IndexBlock(block *Block) []TxLocation {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	for _, tx := range block.Transactions {
+		txHash := hex.EncodeToString(tx.Hash)
+		patientID := KeyID(tx.Patient)
+		doctorID := KeyID(tx.Doctor)
+		ix.Patients[patientID] = append(ix.Patients[patientID], txHash)
+		ix.Doctors[doctorID] = append(ix.Doctors[doctorID], txHash)
+	}
+
+	if block.ID < ix.TxIndex.Height {
+		return nil
+	}
+	return ix.TxIndex.IndexBlock(block)
+}
+
+// PatientTxHashes liefert die Transaktions-Hashes eines Patienten in Blockreihenfolge
+func (ix *Indexer) PatientTxHashes(patientID string) []string {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+	return append([]string(nil), ix.Patients[patientID]...)
+}
+
+// DoctorTxHashes liefert die Transaktions-Hashes eines Arztes in Blockreihenfolge
+func (ix *Indexer) DoctorTxHashes(doctorID string) []string {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+	return append([]string(nil), ix.Doctors[doctorID]...)
+}
+
+func (ix *Indexer) Lookup(txHash string) (TxLocation, bool) {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+	return ix.TxIndex.Lookup(txHash)
+}
+
+// Stats zählt die indizierten Transaktionen, Patienten und Ärzte
+func (ix *Indexer) Stats() Stats {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+	return Stats{Transactions: len(ix.TxIndex.Locations), Patients: len(ix.Patients), Doctors: len(ix.Doctors)}
+}
@@ -0,0 +1,35 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexerTracksPatientsAndDoctors(t *testing.T) {
+	authority := newTestSigner(t)
+	doctor := newTestSigner(t)
+	patientA := newTestSigner(t)
+	patientB := newTestSigner(t)
+
+	bc := NewBlockchain("ega-test", authority)
+	require.NotNil(t, bc)
+
+	txA, err := NewTransaction("ega-test", 0, "Checkup", "Routine checkup", "All normal", doctor, patientA.PublicKey())
+	require.NoError(t, err)
+	txB, err := NewTransaction("ega-test", 1, "Checkup", "Routine checkup", "All normal", doctor, patientB.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, bc.AddBlock(&Block{ID: 1, PreviousHash: bc.Blocks[0].Hash, Transactions: []*Transaction{txA, txB}}))
+
+	patientATxs := bc.PatientTransactions(KeyID(txA.Patient))
+	require.Len(t, patientATxs, 1)
+	require.Equal(t, txA.Hash, patientATxs[0].Hash)
+	require.Len(t, bc.DoctorTransactions(KeyID(txA.Doctor)), 2)
+
+	// Ein Neuaufbau liefert denselben Stand
+	bc.Indexer = NewIndexer()
+	require.Empty(t, bc.PatientTransactions(KeyID(txA.Patient)))
+	require.NoError(t, bc.Reindex())
+	require.Len(t, bc.PatientTransactions(KeyID(txB.Patient)), 1)
+	require.Len(t, bc.DoctorTransactions(KeyID(txA.Doctor)), 2)
+}
@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// BlockPolicy legt fest, wann und wie große Blöcke der Authority Node erzeugt
+type BlockPolicy struct {
+	MaxTransactions int      `json:"maxTransactions" yaml:"maxTransactions"` // höchstens so viele Transaktionen pro Block, ein voller Pool löst sofort einen Block aus
+	MaxBlockBytes   int      `json:"maxBlockBytes" yaml:"maxBlockBytes"`     // höchstens so viele Bytes (JSON) an Transaktionen pro Block
+	MinInterval     Duration `json:"minInterval" yaml:"minInterval"`         // Mindestabstand zwischen zwei Blöcken
+	MaxInterval     Duration `json:"maxInterval" yaml:"maxInterval"`         // spätestens nach diesem Abstand werden wartende Transaktionen aufgenommen
+	EmptyBlocks     bool     `json:"emptyBlocks" yaml:"emptyBlocks"`         // nach MaxInterval auch ohne Transaktionen einen Block erzeugen (Heartbeat)
+}
+
+// DefaultBlockPolicy entspricht dem bisherigen Verhalten: Block bei 5 Transaktionen, spätestens nach 5 Minuten
+func DefaultBlockPolicy() BlockPolicy {
+	return BlockPolicy{
+		MaxTransactions: 5,
+		MaxBlockBytes:   1 << 20,
+		MaxInterval:     Duration(5 * time.Minute),
+	}
+}
+
+// LoadBlockPolicy liest die Policy aus einer JSON-Datei, fehlende Felder behalten ihren Standardwert
+func LoadBlockPolicy(path string) (BlockPolicy, error) {
+	policy := DefaultBlockPolicy()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return policy, fmt.Errorf("failed to read block policy: %v", err)
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, fmt.Errorf("failed to decode block policy: %v", err)
+	}
+	return policy, policy.Validate()
+}
+
+func (p BlockPolicy) Validate() error {
+	switch {
+	case p.MaxTransactions < 1:
+		return fmt.Errorf("block policy: maxTransactions must be at least 1")
+	case p.MaxBlockBytes < 1:
+		return fmt.Errorf("block policy: maxBlockBytes must be at least 1")
+	case p.MaxInterval <= 0:
+		return fmt.Errorf("block policy: maxInterval must be positive")
+	case p.MinInterval < 0 || p.MinInterval > p.MaxInterval:
+		return fmt.Errorf("block policy: minInterval must be between 0 and maxInterval")
+	}
+	return nil
+}
+
+// SelectTransactions wählt die Transaktionen für den nächsten Block. Die Transaktionen jedes Arztes müssen
+// nach Nonce sortiert sein, ausgewählt wird ein Präfix, damit kein Arzt eine Lücke in seinen Nonces erhält.
+func (p BlockPolicy) SelectTransactions(transactions []*Transaction) []*Transaction {
+	size := 0
+	for i, tx := range transactions {
+		if i == p.MaxTransactions {
+			return transactions[:i]
+		}
+		size += TransactionSize(tx)
+		if size > p.MaxBlockBytes {
+			return transactions[:i]
+		}
+	}
+	return transactions
+}
+
+// TransactionSize ist die Größe der Transaktion im Block (JSON)
+func TransactionSize(tx *Transaction) int {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// Duration wird in JSON und YAML als Go-Dauer ("30s", "5m0s") geschrieben
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText und UnmarshalText werden für YAML und Umgebungsvariablen verwendet
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
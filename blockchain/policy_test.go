@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockPolicySelectsPrefix(t *testing.T) {
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	var transactions []*Transaction
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		tx, err := NewTransaction("ega-test", nonce, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+		require.NoError(t, err)
+		transactions = append(transactions, tx)
+	}
+
+	policy := DefaultBlockPolicy()
+	policy.MaxTransactions = 3
+	require.Len(t, policy.SelectTransactions(transactions), 3)
+
+	// Die Größenbegrenzung schneidet ebenfalls nur am Ende ab
+	policy.MaxBlockBytes = 2*TransactionSize(transactions[0]) + 1
+	selected := policy.SelectTransactions(transactions)
+	require.Len(t, selected, 2)
+	require.Equal(t, uint64(1), selected[1].Nonce)
+}
+
+func TestBlockPolicyJSON(t *testing.T) {
+	policy := DefaultBlockPolicy()
+	require.NoError(t, json.Unmarshal([]byte(`{"maxTransactions":50,"minInterval":"10s","emptyBlocks":true}`), &policy))
+	require.Equal(t, 50, policy.MaxTransactions)
+	require.Equal(t, Duration(10*time.Second), policy.MinInterval)
+	require.Equal(t, Duration(5*time.Minute), policy.MaxInterval)
+	require.NoError(t, policy.Validate())
+
+	data, err := json.Marshal(policy)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"maxInterval":"5m0s"`)
+
+	policy.MinInterval = Duration(time.Hour)
+	require.Error(t, policy.Validate())
+}
@@ -0,0 +1,132 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	journalAdd    = "add"
+	journalRemove = "remove"
+)
+
+// PoolJournal ist das Write-Ahead-Journal des Transaktionspools (JSON Lines). Jede aufgenommene Transaktion
+// wird vor der Aufnahme mit fsync geschrieben, damit sie einen Absturz vor dem nächsten Block übersteht.
+type PoolJournal struct {
+	path string
+	file *os.File
+}
+
+// journalRecord ist eine Zeile im Journal: eine aufgenommene oder eine entfernte Transaktion
+type journalRecord struct {
+	Op          string       `json:"op"`
+	Hash        string       `json:"hash"`
+	AddedAt     int64        `json:"addedAt,omitempty"` // Unix-Zeit in Nanosekunden, für die TTL
+	Transaction *Transaction `json:"transaction,omitempty"`
+}
+
+func OpenPoolJournal(path string) (*PoolJournal, error) {
+	if err := truncatePartialLine(path); err != nil {
+		return nil, fmt.Errorf("failed to open pool journal: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pool journal: %v", err)
+	}
+	return &PoolJournal{path: path, file: file}, nil
+}
+
+// Load liefert die Transaktionen, die laut Journal noch im Pool sind, in der Reihenfolge ihres Eingangs
+func (j *PoolJournal) Load() ([]*PoolEntry, error) {
+	var order []string
+	entries := make(map[string]*PoolEntry)
+
+	err := readLines(j.path, func(line []byte) error {
+		var record journalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("failed to decode pool journal: %v", err)
+		}
+
+		switch record.Op {
+		case journalAdd:
+			if record.Transaction == nil {
+				return fmt.Errorf("pool journal: add without transaction")
+			}
+			if _, exists := entries[record.Hash]; !exists {
+				order = append(order, record.Hash)
+			}
+			entries[record.Hash] = &PoolEntry{Transaction: record.Transaction, AddedAt: time.Unix(0, record.AddedAt)}
+		case journalRemove:
+			delete(entries, record.Hash)
+		default:
+			return fmt.Errorf("pool journal: unknown operation %q", record.Op)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*PoolEntry
+	for _, hash := range order {
+		if entry, exists := entries[hash]; exists {
+			pending = append(pending, entry)
+			delete(entries, hash)
+		}
+	}
+	return pending, nil
+}
+
+func (j *PoolJournal) add(entry *PoolEntry) error {
+	return appendLine(j.file, journalRecord{
+		Op:          journalAdd,
+		Hash:        hex.EncodeToString(entry.Transaction.Hash),
+		AddedAt:     entry.AddedAt.UnixNano(),
+		Transaction: entry.Transaction,
+	})
+}
+
+func (j *PoolJournal) remove(transactionHash string) error {
+	return appendLine(j.file, journalRecord{Op: journalRemove, Hash: transactionHash})
+}
+
+// Compact ersetzt das Journal durch die noch wartenden Transaktionen. Die neue Datei wird vollständig
+// geschrieben und erst dann umbenannt, ein Absturz hinterlässt also das alte oder das neue Journal.
+func (j *PoolJournal) Compact(entries []*PoolEntry) error {
+	tmpPath := j.path + ".tmp"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to compact pool journal: %v", err)
+	}
+
+	compacted := &PoolJournal{path: tmpPath, file: tmpFile}
+	for _, entry := range entries {
+		if err := compacted.add(entry); err != nil {
+			tmpFile.Close()
+			return fmt.Errorf("failed to compact pool journal: %v", err)
+		}
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to compact pool journal: %v", err)
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return fmt.Errorf("failed to compact pool journal: %v", err)
+	}
+
+	// Weitere Einträge werden an die neue Datei angehängt
+	file, err := os.OpenFile(j.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen pool journal: %v", err)
+	}
+	j.file.Close()
+	j.file = file
+	return nil
+}
+
+func (j *PoolJournal) Close() error {
+	return j.file.Close()
+}
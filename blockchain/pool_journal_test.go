@@ -0,0 +1,65 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Das Journal liefert nach einem Absturz die wartenden Transaktionen in ihrer Reihenfolge samt Eingangszeit
+func TestPoolJournalReplaysPendingTransactions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.journal")
+	journal, err := OpenPoolJournal(path)
+	require.NoError(t, err)
+
+	pool := NewTransactionPool(DefaultPoolLimits())
+	require.NoError(t, pool.UseJournal(journal))
+	patient := newTestSigner(t)
+	doctorA, doctorB := newTestSigner(t), newTestSigner(t)
+
+	first := newPoolTransaction(t, doctorA, patient, 0)
+	second := newPoolTransaction(t, doctorB, patient, 0)
+	third := newPoolTransaction(t, doctorA, patient, 1)
+	for _, tx := range []*Transaction{first, second, third} {
+		require.NoError(t, pool.AddTransactionToPool(tx))
+	}
+	require.NoError(t, pool.RemoveTransactionFromPool(hex.EncodeToString(second.Hash)))
+	addedAt := pool.Entries()[0].AddedAt
+
+	// Absturz mitten im Schreiben der nächsten Zeile
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"op":"add","hash":"ab`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened, err := OpenPoolJournal(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	entries, err := reopened.Load()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, first.Hash, entries[0].Transaction.Hash)
+	require.Equal(t, third.Hash, entries[1].Transaction.Hash)
+	require.True(t, addedAt.Equal(entries[0].AddedAt))
+
+	// Nach dem Kompaktieren enthält das Journal nur noch die wartenden Transaktionen
+	restored := NewTransactionPool(DefaultPoolLimits())
+	require.NoError(t, restored.Restore(entries[1]))
+	require.NoError(t, restored.UseJournal(reopened))
+	entries, err = reopened.Load()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, third.Hash, entries[0].Transaction.Hash)
+
+	// Weitere Einträge landen im kompaktierten Journal
+	require.NoError(t, restored.AddTransactionToPool(second))
+	entries, err = reopened.Load()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.WithinDuration(t, time.Now(), entries[1].AddedAt, time.Minute)
+}
@@ -0,0 +1,222 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	blocksFileName  = "blocks.jsonl"
+	txIndexFileName = "txindex.jsonl"
+)
+
+// BlockStore speichert Blöcke und den Transaktionsindex als JSON Lines im Datenverzeichnis.
+// Beide Dateien werden nur angehängt, jeder Schreibvorgang wird mit fsync abgeschlossen.
+type BlockStore struct {
+	Dir         string
+	blocksFile  *os.File
+	txIndexFile *os.File
+}
+
+// txIndexRecord ist eine Zeile im Index: entweder eine Transaktion oder das Ende eines Blocks
+type txIndexRecord struct {
+	Location *TxLocation `json:"tx,omitempty"`
+	BlockEnd *uint64     `json:"blockEnd,omitempty"`
+}
+
+func OpenBlockStore(dir string) (*BlockStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	for _, name := range []string{blocksFileName, txIndexFileName} {
+		if err := truncatePartialLine(filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+	}
+
+	blocksFile, err := os.OpenFile(filepath.Join(dir, blocksFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open block store: %v", err)
+	}
+
+	txIndexFile, err := os.OpenFile(filepath.Join(dir, txIndexFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		blocksFile.Close()
+		return nil, fmt.Errorf("failed to open tx index: %v", err)
+	}
+
+	return &BlockStore{Dir: dir, blocksFile: blocksFile, txIndexFile: txIndexFile}, nil
+}
+
+func (s *BlockStore) LoadBlocks() ([]*Block, error) {
+	var blocks []*Block
+	err := readLines(filepath.Join(s.Dir, blocksFileName), func(line []byte) error {
+		var block Block
+		if err := json.Unmarshal(line, &block); err != nil {
+			return fmt.Errorf("failed to decode stored block: %v", err)
+		}
+		blocks = append(blocks, &block)
+		return nil
+	})
+	return blocks, err
+}
+
+func (s *BlockStore) AppendBlock(block *Block) error {
+	return appendLine(s.blocksFile, block)
+}
+
+// Append schreibt einen Block samt Indexeinträgen. Schlägt ein Schreibvorgang fehl, werden beide
+// Dateien auf den vorherigen Stand gekürzt, damit der Store nicht weiter ist als die Blockchain im Speicher.
+func (s *BlockStore) Append(block *Block, entries []TxLocation) error {
+	blocksSize, err := fileSize(s.blocksFile)
+	if err != nil {
+		return err
+	}
+	txIndexSize, err := fileSize(s.txIndexFile)
+	if err != nil {
+		return err
+	}
+
+	err = s.AppendBlock(block)
+	if err == nil {
+		err = s.AppendTxIndex(block.ID, entries)
+	}
+	if err != nil {
+		if rollbackErr := errors.Join(s.blocksFile.Truncate(blocksSize), s.txIndexFile.Truncate(txIndexSize)); rollbackErr != nil {
+			return fmt.Errorf("%v (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// LoadTxIndex liest den Index bis zum letzten vollständig geschriebenen Block
+func (s *BlockStore) LoadTxIndex() (*TxIndex, error) {
+	index := NewTxIndex()
+	var pending []TxLocation
+
+	err := readLines(filepath.Join(s.Dir, txIndexFileName), func(line []byte) error {
+		var record txIndexRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("failed to decode tx index entry: %v", err)
+		}
+		if record.Location != nil {
+			pending = append(pending, *record.Location)
+		}
+		if record.BlockEnd != nil {
+			for _, location := range pending {
+				index.Locations[location.Hash] = location
+			}
+			pending = nil
+			index.Height = *record.BlockEnd + 1
+		}
+		return nil
+	})
+
+	return index, err
+}
+
+// AppendTxIndex schreibt die Einträge eines Blocks gefolgt von einer Endmarke
+func (s *BlockStore) AppendTxIndex(blockID uint64, entries []TxLocation) error {
+	for i := range entries {
+		if err := appendLine(s.txIndexFile, txIndexRecord{Location: &entries[i]}); err != nil {
+			return err
+		}
+	}
+	return appendLine(s.txIndexFile, txIndexRecord{BlockEnd: &blockID})
+}
+
+// RewriteTxIndex ersetzt den gespeicherten Index vollständig, z.B. nach einem Neuaufbau
+func (s *BlockStore) RewriteTxIndex(blocks []*Block) error {
+	if err := s.txIndexFile.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate tx index: %v", err)
+	}
+
+	index := NewTxIndex()
+	for _, block := range blocks {
+		if err := s.AppendTxIndex(block.ID, index.IndexBlock(block)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BlockStore) Close() error {
+	return errors.Join(s.blocksFile.Close(), s.txIndexFile.Close())
+}
+
+func appendLine(file *os.File, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize entry: %v", err)
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %v", file.Name(), err)
+	}
+	return file.Sync()
+}
+
+func fileSize(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %v", file.Name(), err)
+	}
+	return info.Size(), nil
+}
+
+// readLines ruft fn für jede Zeile auf. Eine unvollständige letzte Zeile wird ignoriert.
+func readLines(path string, fn func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Ohne abschließenden Zeilenumbruch wurde die Zeile nie vollständig geschrieben
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(line) > 1 {
+			if err := fn(line[:len(line)-1]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// truncatePartialLine entfernt eine beim Absturz unvollständig geschriebene letzte Zeile,
+// damit neue Einträge nicht an ein kaputtes Fragment angehängt werden
+func truncatePartialLine(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	validLength := bytes.LastIndexByte(data, '\n') + 1
+	if validLength == len(data) {
+		return nil
+	}
+
+	fmt.Printf("Discarding %d bytes of incomplete data in %s\n", len(data)-validLength, path)
+	return os.Truncate(path, int64(validLength))
+}
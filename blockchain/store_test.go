@@ -0,0 +1,145 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockStorePersistsBlocksAndTxIndex(t *testing.T) {
+	dir := t.TempDir()
+	authority := newTestSigner(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	store, err := OpenBlockStore(dir)
+	require.NoError(t, err)
+	bc, err := LoadBlockchain("ega-test", store, nil)
+	require.NoError(t, err)
+
+	genesis, err := CreateGenesisBlock("ega-test", authority)
+	require.NoError(t, err)
+	require.NoError(t, bc.AddBlock(genesis))
+
+	tx, err := NewTransaction("ega-test", 0, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	block := &Block{ID: 1, PreviousHash: genesis.Hash, Transactions: []*Transaction{tx}}
+	block.Hash, err = block.CalculateHash("ega-test")
+	require.NoError(t, err)
+	require.NoError(t, block.SignBlock(authority))
+	require.NoError(t, bc.AddBlock(block))
+	require.NoError(t, store.Close())
+
+	// Nach dem Neustart sind Blöcke, Nonces und Index wieder da
+	store, err = OpenBlockStore(dir)
+	require.NoError(t, err)
+	index, err := store.LoadTxIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), index.Height)
+
+	bc, err = LoadBlockchain("ega-test", store, nil)
+	require.NoError(t, err)
+	require.Len(t, bc.Blocks, 2)
+	require.Equal(t, uint64(1), bc.NextNonce(tx.Doctor))
+
+	found, foundBlock, location, ok := bc.GetTransaction(hex.EncodeToString(tx.Hash))
+	require.True(t, ok)
+	require.Equal(t, tx.Hash, found.Hash)
+	require.Equal(t, block.Hash, foundBlock.Hash)
+	require.Equal(t, 0, location.Position)
+	require.NoError(t, store.Close())
+
+	// Ein beschädigter Index wird beim Laden neu aufgebaut
+	require.NoError(t, os.WriteFile(filepath.Join(dir, txIndexFileName), []byte("{\"blockEnd\":7}\n"), 0600))
+	store, err = OpenBlockStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	bc, err = LoadBlockchain("ega-test", store, nil)
+	require.NoError(t, err)
+	_, _, _, ok = bc.GetTransaction(hex.EncodeToString(tx.Hash))
+	require.True(t, ok)
+
+	index, err = store.LoadTxIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), index.Height)
+}
+
+// writeStoredChain legt im Verzeichnis eine Blockchain aus Genesis-Block und einem von signer signierten Block an
+func writeStoredChain(t *testing.T, dir string, authority, signer utils.Signer) {
+	store, err := OpenBlockStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	bc, err := LoadBlockchain("ega-test", store, nil)
+	require.NoError(t, err)
+
+	genesis, err := CreateGenesisBlock("ega-test", authority)
+	require.NoError(t, err)
+	require.NoError(t, bc.AddBlock(genesis))
+
+	block := &Block{ID: 1, PreviousHash: genesis.Hash, Timestamp: genesis.Timestamp + 1}
+	block.Hash, err = block.CalculateHash("ega-test")
+	require.NoError(t, err)
+	require.NoError(t, block.SignBlock(signer))
+	require.NoError(t, bc.AddBlock(block))
+}
+
+func TestLoadBlockchainRejectsTamperedBlocks(t *testing.T) {
+	authority := newTestSigner(t)
+	authorities := []*ecdsa.PublicKey{authority.PublicKey()}
+
+	// Ein nicht von der Authority signierter Block fällt nur mit festgelegtem Schlüssel auf
+	dir := t.TempDir()
+	writeStoredChain(t, dir, authority, newTestSigner(t))
+	store, err := OpenBlockStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	_, err = LoadBlockchain("ega-test", store, nil)
+	require.NoError(t, err)
+	_, err = LoadBlockchain("ega-test", store, authorities)
+	require.Error(t, err)
+
+	// Ein nachträglich geänderter Block passt nicht mehr zu seinem Hash
+	dir = t.TempDir()
+	writeStoredChain(t, dir, authority, authority)
+	path := filepath.Join(dir, blocksFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	var block Block
+	require.NoError(t, json.Unmarshal(lines[1], &block))
+	block.Timestamp++
+	lines[1], err = json.Marshal(&block)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append(bytes.Join(lines, []byte("\n")), '\n'), 0600))
+
+	store, err = OpenBlockStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+	_, err = LoadBlockchain("ega-test", store, nil)
+	require.Error(t, err)
+}
+
+// Schlägt das Speichern fehl, bleibt die Blockchain im Speicher unverändert
+func TestAddBlockLeavesChainUnchangedWhenPersistFails(t *testing.T) {
+	authority := newTestSigner(t)
+	store, err := OpenBlockStore(t.TempDir())
+	require.NoError(t, err)
+	bc, err := LoadBlockchain("ega-test", store, nil)
+	require.NoError(t, err)
+
+	genesis, err := CreateGenesisBlock("ega-test", authority)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	require.Error(t, bc.AddBlock(genesis))
+	require.Zero(t, bc.Len())
+	require.Zero(t, bc.Stats().Blocks)
+}
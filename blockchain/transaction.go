@@ -3,7 +3,6 @@ package blockchain
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -13,17 +12,37 @@ import (
 
 type Transaction struct {
 	Hash          []byte              `json:"hash"`
+	ChainID       string              `json:"chainId"`
+	Nonce         uint64              `json:"nonce"` // fortlaufende Nummer je Arzt, schützt vor Replays
 	EncryptedData utils.EncryptedData `json:"encryptedData"`
 	Doctor        []byte              `json:"doctor"`
 	Patient       []byte              `json:"patient"`
 	Signature     *Signature          `json:"signature"`
+	// Dringende Einträge (z.B. Allergiewarnung) werden bevorzugt und sofort in einen Block aufgenommen.
+	// Das Flag ist Teil des Hashes, ohne es bleiben Hashes bestehender Transaktionen unverändert.
+	Urgent bool `json:"urgent,omitempty"`
 }
 
+// Signature enthält R und S mit fester Breite von je 32 Bytes, S immer in low-S-Form
 type Signature struct {
 	R []byte `json:"r"`
 	S []byte `json:"s"`
 }
 
+// Bytes liefert die kanonische 64-Byte-Kodierung R || S
+func (sig *Signature) Bytes() ([]byte, error) {
+	return utils.EncodeSignature(sig.R, sig.S)
+}
+
+// SignatureFromBytes liest eine 64-Byte-Signatur und lehnt nicht-kanonische Kodierungen ab
+func SignatureFromBytes(b []byte) (*Signature, error) {
+	r, s, err := utils.DecodeSignature(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{R: r, S: s}, nil
+}
+
 type TransactionData struct {
 	Type      string `json:"type"`
 	Notes     string `json:"notes"`
@@ -41,36 +60,43 @@ func PrepareTransactionData(txType, notes, results string) ([]byte, error) {
 	return json.Marshal(data)
 }
 
-func NewTransaction(txType, notes, results string, senderPrivKey *ecdsa.PrivateKey, recipientPubKey *ecdsa.PublicKey) (*Transaction, error) {
+func NewTransaction(chainID string, nonce uint64, txType, notes, results string, sender utils.Signer, recipientPubKey *ecdsa.PublicKey) (*Transaction, error) {
+	return newTransaction(chainID, nonce, txType, notes, results, false, sender, recipientPubKey)
+}
+
+// NewUrgentTransaction erstellt eine als dringend markierte Transaktion
+func NewUrgentTransaction(chainID string, nonce uint64, txType, notes, results string, sender utils.Signer, recipientPubKey *ecdsa.PublicKey) (*Transaction, error) {
+	return newTransaction(chainID, nonce, txType, notes, results, true, sender, recipientPubKey)
+}
+
+func newTransaction(chainID string, nonce uint64, txType, notes, results string, urgent bool, sender utils.Signer, recipientPubKey *ecdsa.PublicKey) (*Transaction, error) {
 	// Bereite die Transaktionsdaten vor
 	plaintext, err := PrepareTransactionData(txType, notes, results)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare transaction data: %v", err)
 	}
 
-	senderEcdhPrivKey, err := utils.EcdsaPrivToEcdh(senderPrivKey)
-	if err != nil {
-		return nil, fmt.Errorf("Error during conversion from ecdsa to ecdh private key", err)
-	}
-
 	recipientEcdhPubKey, err := utils.EcdsaPubToEcdh(recipientPubKey)
 	if err != nil {
-		return nil, fmt.Errorf("Error during conversion from ecdsa to ecdh public key", err)
+		return nil, fmt.Errorf("error during conversion from ecdsa to ecdh public key: %v", err)
 	}
 
-	// Verschlüssele die Daten mit AES-GCM
-	ciphertext, nonce, err := utils.EncryptData(senderEcdhPrivKey, recipientEcdhPubKey, plaintext)
+	// Verschlüssele die Daten mit AES-GCM, das ECDH-Geheimnis liefert der Signer
+	ciphertext, encryptionNonce, err := utils.EncryptDataWithSigner(sender, recipientEcdhPubKey, plaintext)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt transaction data: %v", err)
 	}
 
 	tx := &Transaction{
-		Doctor:  utils.SerializePublicKey(&senderPrivKey.PublicKey),
+		ChainID: chainID,
+		Nonce:   nonce,
+		Doctor:  utils.SerializePublicKey(sender.PublicKey()),
 		Patient: utils.SerializePublicKey(recipientPubKey),
 		EncryptedData: utils.EncryptedData{
 			Ciphertext: ciphertext,
-			Nonce:      nonce,
+			Nonce:      encryptionNonce,
 		},
+		Urgent: urgent,
 	}
 
 	// Berechne den Hash der Transaktion
@@ -81,13 +107,13 @@ func NewTransaction(txType, notes, results string, senderPrivKey *ecdsa.PrivateK
 	tx.Hash = hash
 
 	// Berechne die Signatur der Transaktion
-	err = tx.SignTransaction(senderPrivKey)
+	err = tx.SignTransaction(sender)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate transaction signature: %v", err)
 	}
 	tx.Hash = hash
 
-	err = tx.ValidateTransaction(&senderPrivKey.PublicKey)
+	err = tx.ValidateTransaction(sender.PublicKey(), chainID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate transaction: %v", err)
 	}
@@ -106,13 +132,13 @@ func (t *Transaction) CalculateHash() ([]byte, error) {
 		return nil, fmt.Errorf("failed to serialize transaction: %v", err)
 	}
 
-	hash := sha256.Sum256(transactionBytes)
-	return hash[:], nil // Rückgabe des Hashes als Slice []byte
+	// Der Hash ist domänengetrennt und wird unverändert signiert
+	return utils.DomainHash(utils.DomainTransaction, t.ChainID, transactionBytes), nil
 }
 
-func (t *Transaction) SignTransaction(privateKey *ecdsa.PrivateKey) error {
+func (t *Transaction) SignTransaction(signer utils.Signer) error {
 	// Signiere den bereits berechneten Hash der Transaktion
-	r, s, err := utils.SignTransaction(privateKey, t.Hash)
+	r, s, err := utils.SignDigest(signer, t.Hash)
 	if err != nil {
 		return fmt.Errorf("failed to generate transaction signature: %v", err)
 	}
@@ -122,12 +148,15 @@ func (t *Transaction) SignTransaction(privateKey *ecdsa.PrivateKey) error {
 		S: s,
 	}
 
-	fmt.Printf("%v", t.Signature)
-
 	return nil
 }
 
-func (t *Transaction) ValidateTransaction(publicKey *ecdsa.PublicKey) error {
+func (t *Transaction) ValidateTransaction(publicKey *ecdsa.PublicKey, chainID string) error {
+	// Transaktionen anderer Chains werden nicht akzeptiert
+	if t.ChainID != chainID {
+		return fmt.Errorf("chain ID mismatch: expected %q, got %q", chainID, t.ChainID)
+	}
+
 	// Berechne den Hash der Transaktion erneut
 	hash, err := t.CalculateHash()
 	if err != nil {
@@ -140,7 +169,10 @@ func (t *Transaction) ValidateTransaction(publicKey *ecdsa.PublicKey) error {
 	}
 
 	// Verifiziere die Signatur mit dem Public Key
-	if !utils.VerifySignature(publicKey, hash, t.Signature.R, t.Signature.S) {
+	if t.Signature == nil {
+		return fmt.Errorf("missing signature for transaction hash %x", t.Hash)
+	}
+	if !utils.VerifyDigest(publicKey, hash, t.Signature.R, t.Signature.S) {
 		return fmt.Errorf("invalid signature for transaction hash %x", t.Hash)
 	}
 
@@ -1,49 +1,286 @@
 package blockchain
 
 import (
+	"cmp"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 )
 
+// Gründe, aus denen der Pool eine Transaktion ablehnt, mit errors.Is prüfbar
+var (
+	ErrPoolDuplicate   = errors.New("transaction already exists in the pool")
+	ErrPoolFull        = errors.New("transaction pool is full")
+	ErrPoolDoctorLimit = errors.New("too many pending transactions from this doctor")
+)
+
+// Gründe, aus denen der Pool eine Transaktion ohne Block entfernt
+const (
+	EvictExpired  = "expired"   // länger als die TTL im Pool
+	EvictNonceGap = "nonce_gap" // eine frühere Transaktion des Arztes wurde entfernt
+	EvictInvalid  = "invalid"   // bei der Blockerstellung nicht mehr gültig
+)
+
+// PoolLimits begrenzt den Transaktionspool des Authority Nodes
+type PoolLimits struct {
+	MaxTransactions int      `json:"maxTransactions" yaml:"maxTransactions"` // höchstens so viele wartende Transaktionen insgesamt
+	MaxPerDoctor    int      `json:"maxPerDoctor" yaml:"maxPerDoctor"`       // höchstens so viele wartende Transaktionen pro Arzt
+	TTL             Duration `json:"ttl" yaml:"ttl"`                         // danach wird eine nicht aufgenommene Transaktion verworfen, 0: nie
+}
+
+func DefaultPoolLimits() PoolLimits {
+	return PoolLimits{
+		MaxTransactions: 10000,
+		MaxPerDoctor:    1000,
+		TTL:             Duration(24 * time.Hour),
+	}
+}
+
+func (l PoolLimits) Validate() error {
+	switch {
+	case l.MaxTransactions < 1:
+		return fmt.Errorf("pool limits: maxTransactions must be at least 1")
+	case l.MaxPerDoctor < 1 || l.MaxPerDoctor > l.MaxTransactions:
+		return fmt.Errorf("pool limits: maxPerDoctor must be between 1 and maxTransactions")
+	case l.TTL < 0:
+		return fmt.Errorf("pool limits: ttl must not be negative")
+	}
+	return nil
+}
+
+// PoolEntry ist eine wartende Transaktion mit dem Zeitpunkt ihrer Aufnahme
+type PoolEntry struct {
+	Transaction *Transaction
+	AddedAt     time.Time
+	sequence    uint64
+}
+
+// TransactionPool hält die wartenden Transaktionen in der Reihenfolge ihres Eingangs. Die Blockerstellung
+// wählt deterministisch in dieser Reihenfolge, dringende Transaktionen zuerst (Priority Lane). Die
+// Transaktionen eines Arztes bleiben dabei nach Nonce sortiert.
+// Mit Journal wird jede Änderung vorher dort festgehalten. Der Pool ist nicht threadsicher, der Authority
+// Node schützt ihn mit seinem Mutex.
 type TransactionPool struct {
-	Transactions map[string]*Transaction
+	Limits PoolLimits
+
+	journal  *PoolJournal
+	entries  map[string]*PoolEntry
+	doctors  map[string]int // wartende Transaktionen je Arzt (KeyID)
+	urgent   int            // wartende dringende Transaktionen
+	sequence uint64
+	clock    func() time.Time
 }
 
-func NewTransactionPool() *TransactionPool {
+func NewTransactionPool(limits PoolLimits) *TransactionPool {
 	return &TransactionPool{
-		Transactions: make(map[string]*Transaction),
+		Limits:  limits,
+		entries: make(map[string]*PoolEntry),
+		doctors: make(map[string]int),
+		clock:   time.Now,
 	}
 }
 
+// UseJournal hält alle weiteren Änderungen im Journal fest und schreibt den aktuellen Stand hinein
+func (tp *TransactionPool) UseJournal(journal *PoolJournal) error {
+	tp.journal = journal
+	return tp.Compact()
+}
+
+// AddTransactionToPool nimmt die Transaktion auf oder lehnt sie mit ErrPoolDuplicate, ErrPoolFull
+// bzw. ErrPoolDoctorLimit ab
 func (tp *TransactionPool) AddTransactionToPool(transaction *Transaction) error {
+	return tp.addEntry(&PoolEntry{Transaction: transaction, AddedAt: tp.clock()}, true)
+}
 
-	transactionHash := fmt.Sprintf("%x", transaction.Hash)
-	if _, exists := tp.Transactions[transactionHash]; exists {
-		return fmt.Errorf("transaction already exists in the pool")
+// Restore nimmt eine Transaktion aus dem Journal wieder auf, die Eingangszeit bleibt für die TTL erhalten
+func (tp *TransactionPool) Restore(entry *PoolEntry) error {
+	return tp.addEntry(&PoolEntry{Transaction: entry.Transaction, AddedAt: entry.AddedAt}, false)
+}
+
+func (tp *TransactionPool) addEntry(entry *PoolEntry, journal bool) error {
+	transaction := entry.Transaction
+	transactionHash := hex.EncodeToString(transaction.Hash)
+	doctorID := KeyID(transaction.Doctor)
+
+	switch {
+	case tp.entries[transactionHash] != nil:
+		return ErrPoolDuplicate
+	case len(tp.entries) >= tp.Limits.MaxTransactions:
+		return fmt.Errorf("%w: limit is %d transactions", ErrPoolFull, tp.Limits.MaxTransactions)
+	case tp.doctors[doctorID] >= tp.Limits.MaxPerDoctor:
+		return fmt.Errorf("%w: limit is %d transactions", ErrPoolDoctorLimit, tp.Limits.MaxPerDoctor)
 	}
 
-	tp.Transactions[transactionHash] = transaction
-	fmt.Printf("Transaction %x added to the pool\n", transaction.Hash)
+	// Write-Ahead: erst im Journal, dann im Pool
+	if journal && tp.journal != nil {
+		if err := tp.journal.add(entry); err != nil {
+			return fmt.Errorf("failed to write pool journal: %v", err)
+		}
+	}
+
+	tp.sequence++
+	entry.sequence = tp.sequence
+	tp.entries[transactionHash] = entry
+	tp.doctors[doctorID]++
+	if transaction.Urgent {
+		tp.urgent++
+	}
+	fmt.Printf("Transaction %s added to the pool\n", transactionHash)
 
 	return nil
 }
 
 func (tp *TransactionPool) RemoveTransactionFromPool(transactionHash string) error {
-	if _, exists := tp.Transactions[transactionHash]; !exists {
+	entry, exists := tp.entries[transactionHash]
+	if !exists {
 		return fmt.Errorf("transaction %s does not exist in the pool", transactionHash)
 	}
 
-	delete(tp.Transactions, transactionHash)
+	// Fehlt der Eintrag im Journal, wird die Transaktion beim Neustart erneut geprüft. Bereits in einen
+	// Block aufgenommene verwirft dann die Nonce-Prüfung.
+	if tp.journal != nil {
+		if err := tp.journal.remove(transactionHash); err != nil {
+			fmt.Printf("Failed to journal removal of transaction %s: %v\n", transactionHash, err)
+		}
+	}
+
+	delete(tp.entries, transactionHash)
+	doctorID := KeyID(entry.Transaction.Doctor)
+	if tp.doctors[doctorID]--; tp.doctors[doctorID] == 0 {
+		delete(tp.doctors, doctorID)
+	}
+	if entry.Transaction.Urgent {
+		tp.urgent--
+	}
 	fmt.Printf("Transaction %s removed from the pool\n", transactionHash)
 
 	return nil
 }
 
+// GetTransactionsFromPool liefert die wartenden Transaktionen in der Reihenfolge für die Blockerstellung:
+// zuerst die dringenden, jeweils nach den früheren Transaktionen desselben Arztes, die sie für eine
+// lückenlose Nonce-Folge brauchen, danach alle übrigen in der Reihenfolge ihres Eingangs
 func (tp *TransactionPool) GetTransactionsFromPool() []*Transaction {
-	var transactions []*Transaction
+	entries := tp.Entries()
+	transactions := make([]*Transaction, 0, len(entries))
+	taken := make(map[*PoolEntry]bool, len(entries))
+	take := func(entry *PoolEntry) {
+		if !taken[entry] {
+			transactions = append(transactions, entry.Transaction)
+			taken[entry] = true
+		}
+	}
 
-	for _, transaction := range tp.Transactions {
-		transactions = append(transactions, transaction)
+	if tp.urgent > 0 {
+		for i, urgent := range entries {
+			if !urgent.Transaction.Urgent {
+				continue
+			}
+			doctorID := KeyID(urgent.Transaction.Doctor)
+			for _, earlier := range entries[:i] {
+				if KeyID(earlier.Transaction.Doctor) == doctorID {
+					take(earlier)
+				}
+			}
+			take(urgent)
+		}
 	}
 
+	for _, entry := range entries {
+		take(entry)
+	}
 	return transactions
 }
+
+// HasUrgent meldet, ob dringende Transaktionen warten
+func (tp *TransactionPool) HasUrgent() bool {
+	return tp.urgent > 0
+}
+
+// Entries liefert die wartenden Transaktionen mit Eingangszeit in der Reihenfolge ihres Eingangs
+func (tp *TransactionPool) Entries() []*PoolEntry {
+	entries := make([]*PoolEntry, 0, len(tp.entries))
+	for _, entry := range tp.entries {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *PoolEntry) int {
+		return cmp.Compare(a.sequence, b.sequence)
+	})
+	return entries
+}
+
+// Get liefert eine wartende Transaktion anhand ihres Hashes (hex)
+func (tp *TransactionPool) Get(transactionHash string) (*Transaction, bool) {
+	entry, exists := tp.entries[transactionHash]
+	if !exists {
+		return nil, false
+	}
+	return entry.Transaction, true
+}
+
+// Len liefert die Anzahl der wartenden Transaktionen
+func (tp *TransactionPool) Len() int {
+	return len(tp.entries)
+}
+
+// CountFromDoctor zählt die wartenden Transaktionen eines Arztes
+func (tp *TransactionPool) CountFromDoctor(doctor []byte) int {
+	return tp.doctors[KeyID(doctor)]
+}
+
+// Evict entfernt die Transaktion und alle späteren desselben Arztes, die ohne sie eine Lücke in den
+// Nonces hätten. Geliefert werden die Hashes der entfernten Transaktionen mit dem jeweiligen Grund.
+func (tp *TransactionPool) Evict(transactionHash, reason string) map[string]string {
+	entry, exists := tp.entries[transactionHash]
+	if !exists {
+		return nil
+	}
+
+	evicted := map[string]string{transactionHash: reason}
+	tp.RemoveTransactionFromPool(transactionHash)
+	for _, later := range tp.Entries() {
+		if later.Transaction.Nonce > entry.Transaction.Nonce && KeyID(later.Transaction.Doctor) == KeyID(entry.Transaction.Doctor) {
+			hash := hex.EncodeToString(later.Transaction.Hash)
+			tp.RemoveTransactionFromPool(hash)
+			evicted[hash] = EvictNonceGap
+		}
+	}
+	return evicted
+}
+
+// Expire entfernt die Transaktionen, die länger als die TTL warten, samt den späteren desselben Arztes
+func (tp *TransactionPool) Expire() map[string]string {
+	if tp.Limits.TTL <= 0 {
+		return nil
+	}
+
+	evicted := make(map[string]string)
+	deadline := tp.clock().Add(-time.Duration(tp.Limits.TTL))
+	for _, entry := range tp.Entries() {
+		hash := hex.EncodeToString(entry.Transaction.Hash)
+		if _, done := evicted[hash]; done || !entry.AddedAt.Before(deadline) {
+			continue
+		}
+		maps.Copy(evicted, tp.Evict(hash, EvictExpired))
+	}
+	return evicted
+}
+
+// Compact ersetzt das Journal durch die wartenden Transaktionen, z.B. nachdem ein Block erzeugt wurde
+func (tp *TransactionPool) Compact() error {
+	if tp.journal == nil {
+		return nil
+	}
+	return tp.journal.Compact(tp.Entries())
+}
+
+// Close schließt das Journal
+func (tp *TransactionPool) Close() error {
+	if tp.journal == nil {
+		return nil
+	}
+	return tp.journal.Close()
+}
@@ -0,0 +1,113 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func newPoolTransaction(t *testing.T, doctor, patient utils.Signer, nonce uint64) *Transaction {
+	tx, err := NewTransaction("ega-test", nonce, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	return tx
+}
+
+// Die Blockerstellung erhält die Transaktionen unabhängig von der Map in der Reihenfolge ihres Eingangs
+func TestPoolKeepsArrivalOrder(t *testing.T) {
+	pool := NewTransactionPool(DefaultPoolLimits())
+	patient := newTestSigner(t)
+	doctorA, doctorB := newTestSigner(t), newTestSigner(t)
+
+	var expected []*Transaction
+	for nonce := range uint64(10) {
+		for _, doctor := range []utils.Signer{doctorB, doctorA} {
+			tx := newPoolTransaction(t, doctor, patient, nonce)
+			require.NoError(t, pool.AddTransactionToPool(tx))
+			expected = append(expected, tx)
+		}
+	}
+
+	for range 5 {
+		require.Equal(t, expected, pool.GetTransactionsFromPool())
+	}
+	require.Equal(t, 10, pool.CountFromDoctor(utils.SerializePublicKey(doctorA.PublicKey())))
+}
+
+func TestPoolRejectsOverLimit(t *testing.T) {
+	pool := NewTransactionPool(PoolLimits{MaxTransactions: 3, MaxPerDoctor: 2})
+	patient := newTestSigner(t)
+	doctorA, doctorB := newTestSigner(t), newTestSigner(t)
+
+	first := newPoolTransaction(t, doctorA, patient, 0)
+	require.NoError(t, pool.AddTransactionToPool(first))
+	require.ErrorIs(t, pool.AddTransactionToPool(first), ErrPoolDuplicate)
+	require.NoError(t, pool.AddTransactionToPool(newPoolTransaction(t, doctorA, patient, 1)))
+	require.ErrorIs(t, pool.AddTransactionToPool(newPoolTransaction(t, doctorA, patient, 2)), ErrPoolDoctorLimit)
+
+	require.NoError(t, pool.AddTransactionToPool(newPoolTransaction(t, doctorB, patient, 0)))
+	require.ErrorIs(t, pool.AddTransactionToPool(newPoolTransaction(t, doctorB, patient, 1)), ErrPoolFull)
+
+	// Nach dem Entfernen ist wieder Platz
+	require.NoError(t, pool.RemoveTransactionFromPool(hex.EncodeToString(first.Hash)))
+	require.Equal(t, 1, pool.CountFromDoctor(utils.SerializePublicKey(doctorA.PublicKey())))
+	require.NoError(t, pool.AddTransactionToPool(newPoolTransaction(t, doctorB, patient, 1)))
+}
+
+// Abgelaufene Transaktionen werden samt den späteren desselben Arztes verworfen, sonst bliebe eine Lücke
+func TestPoolExpiresTransactions(t *testing.T) {
+	pool := NewTransactionPool(PoolLimits{MaxTransactions: 10, MaxPerDoctor: 10, TTL: Duration(time.Hour)})
+	now := time.Unix(1_700_000_000, 0)
+	pool.clock = func() time.Time { return now }
+	patient := newTestSigner(t)
+	doctorA, doctorB := newTestSigner(t), newTestSigner(t)
+
+	old := newPoolTransaction(t, doctorA, patient, 0)
+	other := newPoolTransaction(t, doctorB, patient, 0)
+	require.NoError(t, pool.AddTransactionToPool(old))
+	require.NoError(t, pool.AddTransactionToPool(other))
+
+	now = now.Add(50 * time.Minute)
+	later := newPoolTransaction(t, doctorA, patient, 1)
+	fresh := newPoolTransaction(t, doctorB, patient, 1)
+	require.NoError(t, pool.AddTransactionToPool(later))
+	require.NoError(t, pool.AddTransactionToPool(fresh))
+	require.Empty(t, pool.Expire())
+
+	now = now.Add(20 * time.Minute)
+	// other ist ebenfalls abgelaufen, fresh desselben Arztes hat ohne other eine Lücke
+	require.Equal(t, map[string]string{
+		hex.EncodeToString(old.Hash):   EvictExpired,
+		hex.EncodeToString(later.Hash): EvictNonceGap,
+		hex.EncodeToString(other.Hash): EvictExpired,
+		hex.EncodeToString(fresh.Hash): EvictNonceGap,
+	}, pool.Expire())
+	require.Zero(t, pool.Len())
+	require.Zero(t, pool.CountFromDoctor(utils.SerializePublicKey(doctorA.PublicKey())))
+}
+
+// Dringende Transaktionen kommen zuerst, ohne die Nonce-Reihenfolge ihres Arztes zu verletzen
+func TestPoolPrioritizesUrgentTransactions(t *testing.T) {
+	pool := NewTransactionPool(DefaultPoolLimits())
+	patient := newTestSigner(t)
+	doctorA, doctorB := newTestSigner(t), newTestSigner(t)
+
+	a0 := newPoolTransaction(t, doctorA, patient, 0)
+	b0 := newPoolTransaction(t, doctorB, patient, 0)
+	a1 := newPoolTransaction(t, doctorA, patient, 1)
+	b1, err := NewUrgentTransaction("ega-test", 1, "Allergy", "Penicillin", "", doctorB, patient.PublicKey())
+	require.NoError(t, err)
+	a2 := newPoolTransaction(t, doctorA, patient, 2)
+	for _, tx := range []*Transaction{a0, b0, a1, b1, a2} {
+		require.NoError(t, pool.AddTransactionToPool(tx))
+	}
+
+	require.True(t, pool.HasUrgent())
+	require.Equal(t, []*Transaction{b0, b1, a0, a1, a2}, pool.GetTransactionsFromPool())
+
+	require.NoError(t, pool.RemoveTransactionFromPool(hex.EncodeToString(b1.Hash)))
+	require.False(t, pool.HasUrgent())
+	require.Equal(t, []*Transaction{a0, b0, a1, a2}, pool.GetTransactionsFromPool())
+}
@@ -3,10 +3,49 @@
 package blockchain
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"encoding/hex"
+	"testing"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
 )
 
+func newTestSigner(t *testing.T) utils.Signer {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Failed to generate key")
+	return utils.NewMemorySigner(privKey)
+}
+
+func TestTransactionSignatureDomainSeparation(t *testing.T) {
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	tx, err := NewTransaction("ega-test", 0, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+	require.NoError(t, err, "Failed to create transaction")
+	require.NoError(t, tx.ValidateTransaction(doctor.PublicKey(), "ega-test"))
+
+	// Dieselbe Transaktion ist auf einer anderen Chain ungültig
+	require.Error(t, tx.ValidateTransaction(doctor.PublicKey(), "ega-other"))
+
+	// Eine Blocksignatur desselben Schlüssels darf nicht als Transaktionssignatur durchgehen
+	block := &Block{ID: 1, Transactions: []*Transaction{}}
+	block.Hash, err = block.CalculateHash("ega-test")
+	require.NoError(t, err)
+	require.NoError(t, block.SignBlock(doctor))
+
+	forged := *tx
+	forged.Hash = block.Hash
+	forged.Signature = block.Signature
+	require.Error(t, forged.ValidateTransaction(doctor.PublicKey(), "ega-test"), "Block signature must not validate a transaction")
+
+	// Manipulierte Daten werden erkannt
+	tx.EncryptedData.Ciphertext = []byte("tampered data")
+	require.Error(t, tx.ValidateTransaction(doctor.PublicKey(), "ega-test"))
+}
+
 func generateRandomHexKey(length int) (string, error) {
 	bytes := make([]byte, length)
 	_, err := rand.Read(bytes)
@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+)
+
+// TxLocation beschreibt, wo eine Transaktion in der Blockchain liegt
+type TxLocation struct {
+	Hash      string `json:"hash"`
+	BlockID   uint64 `json:"blockId"`
+	BlockHash []byte `json:"blockHash"`
+	Position  int    `json:"position"`
+}
+
+// TxIndex bildet Transaktions-Hashes (hex) auf ihre Position in der Blockchain ab
+type TxIndex struct {
+	Locations map[string]TxLocation
+	Height    uint64 // Anzahl der bereits indizierten Blöcke
+}
+
+func NewTxIndex() *TxIndex {
+	return &TxIndex{Locations: make(map[string]TxLocation)}
+}
+
+// IndexBlock nimmt alle Transaktionen des Blocks in den Index auf und liefert die neuen Einträge
+func (idx *TxIndex) IndexBlock(block *Block) []TxLocation {
+	entries := TxLocations(block)
+	for _, location := range entries {
+		idx.Locations[location.Hash] = location
+	}
+	idx.Height++
+	return entries
+}
+
+// TxLocations liefert die Indexeinträge aller Transaktionen des Blocks
+func TxLocations(block *Block) []TxLocation {
+	entries := make([]TxLocation, 0, len(block.Transactions))
+	for position, tx := range block.Transactions {
+		entries = append(entries, TxLocation{
+			Hash:      hex.EncodeToString(tx.Hash),
+			BlockID:   block.ID,
+			BlockHash: block.Hash,
+			Position:  position,
+		})
+	}
+	return entries
+}
+
+func (idx *TxIndex) Lookup(txHash string) (TxLocation, bool) {
+	location, exists := idx.Locations[txHash]
+	return location, exists
+}
+
+// Verify prüft, ob alle Einträge auf die angegebenen Blöcke verweisen
+func (idx *TxIndex) Verify(blocks []*Block) error {
+	if idx.Height > uint64(len(blocks)) {
+		return fmt.Errorf("tx index height %d exceeds chain height %d", idx.Height, len(blocks))
+	}
+
+	for txHash, location := range idx.Locations {
+		if location.BlockID >= idx.Height {
+			return fmt.Errorf("tx %s points to unindexed block %d", txHash, location.BlockID)
+		}
+		block := blocks[location.BlockID]
+		if !bytes.Equal(block.Hash, location.BlockHash) || location.Position >= len(block.Transactions) {
+			return fmt.Errorf("tx %s points to unknown block %x", txHash, location.BlockHash)
+		}
+		if hex.EncodeToString(block.Transactions[location.Position].Hash) != txHash {
+			return fmt.Errorf("tx %s not found at position %d of block %d", txHash, location.Position, location.BlockID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/webhook"
+)
+
+// DefaultTimeout begrenzt jede Anfrage, sofern der Context kein früheres Ende vorgibt
+const DefaultTimeout = 10 * time.Second
+
+// NodeClient ist ein typisierter Client für die HTTP-API eines Nodes
+type NodeClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	signer      utils.Signer // signiert jede Anfrage (EGA-ECDSA), falls gesetzt
+	chainID     string
+	bearerToken string // Session-Token aus Login, falls gesetzt
+	tls         bool
+}
+
+type Option func(*NodeClient)
+
+// WithTimeout setzt das Timeout pro Anfrage
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *NodeClient) {
+		c.HTTPClient.Timeout = timeout
+	}
+}
+
+// WithHTTPClient ersetzt den HTTP-Client, z.B. für eigene Transports
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *NodeClient) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// WithTLS verbindet per HTTPS mit der angegebenen Konfiguration (CA, Client-Zertifikat für mTLS).
+// Adressen ohne Schema werden dann mit https:// angesprochen.
+func WithTLS(config *tls.Config) Option {
+	return func(c *NodeClient) {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		c.HTTPClient.Transport = transport
+		c.tls = true
+	}
+}
+
+// WithSigner signiert jede Anfrage mit dem Schlüssel des Aufrufers für Nodes mit aktivierter Authentifizierung
+func WithSigner(signer utils.Signer, chainID string) Option {
+	return func(c *NodeClient) {
+		c.signer = signer
+		c.chainID = chainID
+	}
+}
+
+// WithBearerToken sendet ein Session-Token (siehe Login) statt signierter Anfragen
+func WithBearerToken(token string) Option {
+	return func(c *NodeClient) {
+		c.bearerToken = token
+	}
+}
+
+// NewNodeClient erstellt einen Client für eine Node-Adresse ("host:port") oder eine vollständige URL
+func NewNodeClient(address string, options ...Option) *NodeClient {
+	c := &NodeClient{
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	baseURL := address
+	if !strings.Contains(address, "://") {
+		if c.tls {
+			baseURL = "https://" + address
+		} else {
+			baseURL = "http://" + address
+		}
+	}
+	c.BaseURL = strings.TrimRight(baseURL, "/")
+	return c
+}
+
+func (c *NodeClient) AddTransaction(ctx context.Context, tx *blockchain.Transaction) error {
+	return c.do(ctx, http.MethodPost, "/addTransaction", nil, tx, nil)
+}
+
+// AddTransactions reicht bis zu MaxBatchSize Transaktionen ein. Abgelehnte Transaktionen sind kein Fehler,
+// sondern stehen mit Grund in den Ergebnissen.
+func (c *NodeClient) AddTransactions(ctx context.Context, transactions []*blockchain.Transaction) (*BatchResponse, error) {
+	var response BatchResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/transactions:batch", nil, TransactionBatch{Transactions: transactions}, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+func (c *NodeClient) CreateBlock(ctx context.Context) (*blockchain.Block, error) {
+	var block blockchain.Block
+	if err := c.do(ctx, http.MethodGet, "/createBlock", nil, nil, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (c *NodeClient) GetTransactionPool(ctx context.Context) (map[string]*blockchain.Transaction, error) {
+	var pool map[string]*blockchain.Transaction
+	if err := c.do(ctx, http.MethodGet, "/getTransactionPool", nil, nil, &pool); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Sync liefert alle Blöcke nach dem Block mit dem angegebenen Hash (hex), bei leerem Hash die ganze Kette
+func (c *NodeClient) Sync(ctx context.Context, lastBlockHash string) ([]*blockchain.Block, error) {
+	var response SyncResponse
+	if err := c.do(ctx, http.MethodPost, "/sync", nil, SyncRequest{LastBlockHash: lastBlockHash}, &response); err != nil {
+		return nil, err
+	}
+	return response.Blocks, nil
+}
+
+func (c *NodeClient) GetPublicKey(ctx context.Context) (*ecdsa.PublicKey, error) {
+	var response PublicKeyResponse
+	if err := c.do(ctx, http.MethodGet, "/getPublicKey", nil, nil, &response); err != nil {
+		return nil, err
+	}
+
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(response.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding base64 public key: %v", err)
+	}
+	return utils.DeserializePublicKey(publicKeyBytes)
+}
+
+// GetNonce liefert die nächste freie Nonce für den Arzt (serialisierter Public Key)
+func (c *NodeClient) GetNonce(ctx context.Context, doctor []byte) (uint64, error) {
+	var response NonceResponse
+	query := url.Values{"doctorID": {blockchain.KeyID(doctor)}}
+	if err := c.do(ctx, http.MethodGet, "/getNonce", query, nil, &response); err != nil {
+		return 0, err
+	}
+	return response.Nonce, nil
+}
+
+func (c *NodeClient) GetTransaction(ctx context.Context, txHash string) (*TransactionStatus, error) {
+	var status TransactionStatus
+	if err := c.do(ctx, http.MethodGet, "/tx/"+url.PathEscape(txHash), nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetPatientTransactions liefert die (verschlüsselten) Transaktionen des Patienten (serialisierter Public Key)
+func (c *NodeClient) GetPatientTransactions(ctx context.Context, patient []byte) ([]*blockchain.Transaction, error) {
+	var transactions []*blockchain.Transaction
+	query := url.Values{"patientID": {blockchain.KeyID(patient)}}
+	if err := c.do(ctx, http.MethodGet, "/getPatientTransactions", query, nil, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (c *NodeClient) Reindex(ctx context.Context) (*ReindexResult, error) {
+	var result ReindexResult
+	if err := c.do(ctx, http.MethodPost, "/reindex", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *NodeClient) ListBlocks(ctx context.Context, from uint64, limit int) (*BlockPage, error) {
+	var page BlockPage
+	query := url.Values{"from": {strconv.FormatUint(from, 10)}, "limit": {strconv.Itoa(limit)}}
+	if err := c.do(ctx, http.MethodGet, "/v1/blocks", query, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetBlock liefert einen Block anhand seiner ID, seines Hashes (hex) oder "latest"
+func (c *NodeClient) GetBlock(ctx context.Context, ref string) (*blockchain.Block, error) {
+	var block blockchain.Block
+	if err := c.do(ctx, http.MethodGet, "/v1/blocks/"+url.PathEscape(ref), nil, nil, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (c *NodeClient) GetChainInfo(ctx context.Context) (*ChainInfo, error) {
+	var info ChainInfo
+	if err := c.do(ctx, http.MethodGet, "/v1/chain/info", nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Login beweist den Besitz des Schlüssels über eine Challenge und verwendet das erhaltene
+// Session-Token für alle weiteren Anfragen dieses Clients
+func (c *NodeClient) Login(ctx context.Context, signer utils.Signer, chainID string) error {
+	keyID := blockchain.KeyID(utils.SerializePublicKey(signer.PublicKey()))
+
+	var challenge ChallengeResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/auth/challenge", nil, ChallengeRequest{KeyID: keyID}, &challenge); err != nil {
+		return err
+	}
+
+	signature, err := auth.SignChallenge(signer, chainID, keyID, challenge.Challenge)
+	if err != nil {
+		return err
+	}
+
+	var token TokenResponse
+	request := TokenRequest{KeyID: keyID, Challenge: challenge.Challenge, Signature: signature}
+	if err := c.do(ctx, http.MethodPost, "/v1/auth/token", nil, request, &token); err != nil {
+		return err
+	}
+
+	c.bearerToken = token.Token
+	return nil
+}
+
+func (c *NodeClient) RegisterWebhook(ctx context.Context, registration WebhookRegistration) (*webhook.Webhook, error) {
+	var registered webhook.Webhook
+	if err := c.do(ctx, http.MethodPost, "/v1/webhooks", nil, registration, &registered); err != nil {
+		return nil, err
+	}
+	return &registered, nil
+}
+
+func (c *NodeClient) ListWebhooks(ctx context.Context) ([]*webhook.Webhook, error) {
+	var webhooks []*webhook.Webhook
+	if err := c.do(ctx, http.MethodGet, "/v1/webhooks", nil, nil, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (c *NodeClient) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/webhooks/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// GetWebhookDeliveries liefert das Zustellprotokoll eines Webhooks, die neuesten Zustellungen zuerst
+func (c *NodeClient) GetWebhookDeliveries(ctx context.Context, id string) ([]webhook.Delivery, error) {
+	var deliveries []webhook.Delivery
+	if err := c.do(ctx, http.MethodGet, "/v1/webhooks/"+url.PathEscape(id)+"/deliveries", nil, nil, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// do sendet die Anfrage, kodiert body als JSON und dekodiert die Antwort in result (falls nicht nil)
+func (c *NodeClient) do(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to serialize request: %v", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(req, data); err != nil {
+		return err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode response from %s: %v", path, err)
+	}
+	return nil
+}
+
+// authorize setzt den Authorization-Header, sofern ein Signer oder ein Session-Token konfiguriert ist
+func (c *NodeClient) authorize(req *http.Request, body []byte) error {
+	switch {
+	case c.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	case c.signer != nil:
+		return auth.SignRequest(req, body, c.signer, c.chainID)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeClientDecodesResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/blocks", r.URL.Path)
+		require.Equal(t, "5", r.URL.Query().Get("from"))
+		require.Equal(t, "10", r.URL.Query().Get("limit"))
+		json.NewEncoder(w).Encode(BlockPage{NextCursor: "15"})
+	}))
+	defer server.Close()
+
+	page, err := NewNodeClient(server.URL).ListBlocks(context.Background(), 5, 10)
+	require.NoError(t, err)
+	require.Equal(t, "15", page.NextCursor)
+}
+
+func TestNodeClientTypedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "transaction not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := NewNodeClient(server.URL).GetTransaction(context.Background(), "00")
+	require.Error(t, err)
+	require.True(t, errors.Is(err, ErrNotFound))
+	require.False(t, errors.Is(err, ErrServer))
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, "transaction not found", apiErr.Message)
+}
+
+func TestNewNodeClientAddsScheme(t *testing.T) {
+	require.Equal(t, "http://localhost:8080", NewNodeClient("localhost:8080").BaseURL)
+	require.Equal(t, "https://node.example", NewNodeClient("https://node.example/").BaseURL)
+}
+
+func TestSubscribeEventsParsesStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "3", r.URL.Query().Get("from"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: ready\ndata: {\"chainId\":\"ega-test\"}\n\n" +
+			": keepalive\n\n" +
+			"id: 3\nevent: block\ndata: {\"ID\":3}\n\n" +
+			"event: pool\ndata: {\"action\":\"added\",\"hash\":\"ab\"}\n\n"))
+	}))
+	defer server.Close()
+
+	var events []Event
+	err := NewNodeClient(server.URL).SubscribeEvents(context.Background(), 3, func(event Event) error {
+		events = append(events, event)
+		return nil
+	})
+	require.Error(t, err, "stream end must be reported so the caller can reconnect")
+	require.Len(t, events, 3)
+
+	info, err := events[0].ChainInfo()
+	require.NoError(t, err)
+	require.Equal(t, "ega-test", info.ChainID)
+
+	require.Equal(t, EventBlock, events[1].Type)
+	require.Equal(t, "3", events[1].ID)
+	block, err := events[1].Block()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), block.ID)
+
+	poolEvent, err := events[2].PoolEvent()
+	require.NoError(t, err)
+	require.Equal(t, PoolEventAdded, poolEvent.Action)
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+)
+
+// Event ist ein einzelnes Ereignis aus dem Event-Stream
+type Event struct {
+	Type string
+	ID   string
+	Data []byte
+}
+
+func (e Event) Block() (*blockchain.Block, error) {
+	var block blockchain.Block
+	if err := json.Unmarshal(e.Data, &block); err != nil {
+		return nil, fmt.Errorf("failed to decode block event: %v", err)
+	}
+	return &block, nil
+}
+
+func (e Event) PoolEvent() (*PoolEvent, error) {
+	var event PoolEvent
+	if err := json.Unmarshal(e.Data, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode pool event: %v", err)
+	}
+	return &event, nil
+}
+
+func (e Event) ChainInfo() (*ChainInfo, error) {
+	var info ChainInfo
+	if err := json.Unmarshal(e.Data, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode ready event: %v", err)
+	}
+	return &info, nil
+}
+
+// SubscribeEvents abonniert den Event-Stream ab dem Block from und ruft handle für jedes Ereignis auf.
+// Die Methode blockiert, bis der Context endet, die Verbindung abbricht oder handle einen Fehler liefert.
+func (c *NodeClient) SubscribeEvents(ctx context.Context, from uint64, handle func(Event) error) error {
+	endpoint := c.BaseURL + "/v1/events?" + url.Values{"from": {strconv.FormatUint(from, 10)}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if err := c.authorize(req, nil); err != nil {
+		return err
+	}
+
+	// Der Stream bleibt offen, das Timeout pro Anfrage darf hier nicht greifen
+	streamClient := *c.HTTPClient
+	streamClient.Timeout = 0
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	return readEvents(resp.Body, handle)
+}
+
+// readEvents zerlegt einen text/event-stream in einzelne Ereignisse
+func readEvents(r io.Reader, handle func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var event Event
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			// Leerzeile beendet das Ereignis
+			if len(data) > 0 {
+				event.Data = []byte(strings.Join(data, "\n"))
+				if event.Type == "" {
+					event.Type = "message"
+				}
+				if err := handle(event); err != nil {
+					return err
+				}
+			}
+			event, data = Event{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Kommentar, z.B. Keepalive
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Type = value
+		case "id":
+			event.ID = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("event stream failed: %w", err)
+	}
+	return fmt.Errorf("event stream closed by node")
+}
@@ -0,0 +1,133 @@
+package client
+
+import "github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+
+// Die Typen in dieser Datei sind die Request- und Response-Formate der Node-API (siehe api/openapi.yaml)
+// und werden sowohl von den Handlern im Node als auch vom NodeClient verwendet.
+
+type SyncRequest struct {
+	LastBlockHash string `json:"lastBlockHash"`
+}
+
+type SyncResponse struct {
+	Blocks []*blockchain.Block `json:"blocks"`
+}
+
+type PublicKeyResponse struct {
+	PublicKey string `json:"publicKey"` // Base64 (Standard), unkomprimierter P-256-Punkt
+}
+
+type NonceResponse struct {
+	Nonce uint64 `json:"nonce"`
+}
+
+// TransactionStatus beschreibt, ob eine Transaktion noch im Pool wartet oder bereits in einem Block liegt
+type TransactionStatus struct {
+	Status        string                  `json:"status"`
+	Transaction   *blockchain.Transaction `json:"transaction"`
+	Block         *blockchain.BlockHeader `json:"block,omitempty"`
+	Position      int                     `json:"position"`
+	Confirmations uint64                  `json:"confirmations"`
+}
+
+const (
+	TransactionStatusPending  = "pending"
+	TransactionStatusIncluded = "included"
+)
+
+// MaxBatchSize begrenzt die Anzahl der Transaktionen in einem Aufruf von /v1/transactions:batch
+const MaxBatchSize = 1000
+
+// TransactionBatch reicht mehrere Transaktionen auf einmal ein. Sie werden der Reihe nach wie mit
+// /addTransaction geprüft, aufeinanderfolgende Nonces eines Arztes sind also möglich.
+type TransactionBatch struct {
+	Transactions []*blockchain.Transaction `json:"transactions"`
+}
+
+// BatchResult ist das Ergebnis für eine Transaktion der Batch
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Hash   string `json:"hash,omitempty"`
+	Status int    `json:"status"` // HTTP-Statuscode, den /addTransaction für diese Transaktion geliefert hätte
+	Error  string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Accepted int           `json:"accepted"`
+	Results  []BatchResult `json:"results"` // in der Reihenfolge der Batch
+}
+
+type ReindexResult struct {
+	Blocks       int `json:"blocks"`
+	Transactions int `json:"transactions"`
+	Patients     int `json:"patients"`
+	Doctors      int `json:"doctors"`
+}
+
+// BlockPage ist eine Seite der Blockliste, NextCursor ist die ID des nächsten Blocks
+type BlockPage struct {
+	Blocks     []*blockchain.BlockHeader `json:"blocks"`
+	NextCursor string                    `json:"nextCursor,omitempty"`
+}
+
+type ChainInfo struct {
+	ChainID      string `json:"chainId"`
+	Height       uint64 `json:"height"`
+	TipHash      string `json:"tipHash"`
+	TipTimestamp int64  `json:"tipTimestamp"`
+	GenesisHash  string `json:"genesisHash"`
+
+	// BlockPolicy beschreibt die erwartete Blockerzeugung, Client Nodes übernehmen sie vom Authority Node
+	BlockPolicy *blockchain.BlockPolicy `json:"blockPolicy,omitempty"`
+}
+
+// Ereignistypen des Event-Streams /v1/events (Server-Sent Events)
+const (
+	EventReady = "ready" // erstes Ereignis nach dem Verbindungsaufbau, Daten: ChainInfo
+	EventBlock = "block" // neuer Block, Daten: Block, ID: Block-ID
+	EventPool  = "pool"  // Änderung im Transaktionspool (nur Authority Node), Daten: PoolEvent
+)
+
+// PoolEvent meldet, dass eine Transaktion in den Pool aufgenommen, in einen Block übernommen (removed)
+// oder ohne Block verworfen (evicted, Grund in Reason) wurde
+type PoolEvent struct {
+	Action      string                  `json:"action"`
+	Hash        string                  `json:"hash"`
+	Transaction *blockchain.Transaction `json:"transaction,omitempty"`
+	Reason      string                  `json:"reason,omitempty"`
+}
+
+const (
+	PoolEventAdded   = "added"
+	PoolEventRemoved = "removed"
+	PoolEventEvicted = "evicted"
+)
+
+// WebhookRegistration registriert einen Webhook für neue Einträge eines Patienten (KeyID).
+// Ohne Secret erzeugt der Node eines und gibt es einmalig in der Antwort zurück.
+type WebhookRegistration struct {
+	URL       string `json:"url"`
+	PatientID string `json:"patientId"`
+	Secret    string `json:"secret,omitempty"`
+}
+
+type ChallengeRequest struct {
+	KeyID string `json:"keyId"`
+}
+
+type ChallengeResponse struct {
+	Challenge string `json:"challenge"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// TokenRequest löst eine Challenge ein, Signature ist R || S (Base64) über die Challenge
+type TokenRequest struct {
+	KeyID     string `json:"keyId"`
+	Challenge string `json:"challenge"`
+	Signature []byte `json:"signature"`
+}
+
+type TokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
@@ -2,17 +2,20 @@ package cmd
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
 )
 
-func (a *AuthorityNode) GetPatientTransactionsHandler(w http.ResponseWriter, r *http.Request) {
-	var transactions []*blockchain.Transaction
-
+func (node *Node) GetPatientTransactionsHandler(w http.ResponseWriter, r *http.Request) {
 	patientID := r.URL.Query().Get("patientID")
 	if patientID == "" {
 		http.Error(w, "patientID is required", http.StatusBadRequest)
@@ -26,15 +29,16 @@ func (a *AuthorityNode) GetPatientTransactionsHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	patientHash := base64.URLEncoding.EncodeToString(decodedPatientID)
-	patientData, exists := a.Patients[patientHash]
-	if !exists {
-		http.Error(w, "patient not found", http.StatusNotFound)
+	// Patienten sehen nur ihre eigenen Einträge, Auditoren alle
+	if _, ok := node.Auth.Authorize(w, r, []auth.Role{auth.RoleAuditor}, blockchain.KeyID(decodedPatientID)); !ok {
 		return
 	}
 
-	for _, tx := range patientData.Transactions {
-		transactions = append(transactions, tx)
+	// Die Transaktionen kommen aus dem Patientenindex, der bei jedem Block fortgeschrieben wird
+	transactions := node.Blockchain.PatientTransactions(blockchain.KeyID(decodedPatientID))
+	if len(transactions) == 0 {
+		http.Error(w, "patient not found", http.StatusNotFound)
+		return
 	}
 
 	responseData, err := json.Marshal(transactions)
@@ -48,43 +52,87 @@ func (a *AuthorityNode) GetPatientTransactionsHandler(w http.ResponseWriter, r *
 	w.Write(responseData)
 }
 
-func (node *Node) GetBlockchainHandler(w http.ResponseWriter, r *http.Request) {
-	// Stelle sicher, dass die Blockchain vorhanden ist
-	if node.Blockchain == nil {
-		http.Error(w, "Blockchain not initialized", http.StatusInternalServerError)
-		return
+// LookupTransaction sucht eine Transaktion in der Blockchain, Confirmations zählt die Blöcke ab dem enthaltenden Block
+func (node *Node) LookupTransaction(txHash string) (*client.TransactionStatus, bool) {
+	tx, block, location, exists := node.Blockchain.GetTransaction(txHash)
+	if !exists {
+		return nil, false
 	}
 
-	// Serialisiere die Blockchain
-	blockchainData, err := json.MarshalIndent(node.Blockchain.Blocks, "", "  ")
-	if err != nil {
-		http.Error(w, "Failed to serialize blockchain", http.StatusInternalServerError)
+	return &client.TransactionStatus{
+		Status:        client.TransactionStatusIncluded,
+		Transaction:   tx,
+		Block:         block.Header(),
+		Position:      location.Position,
+		Confirmations: uint64(node.Blockchain.Len()) - block.ID,
+	}, true
+}
+
+// authorizeTransaction erlaubt den Zugriff auf eine Transaktion Lesern der gesamten Blockchain sowie dem
+// beteiligten Arzt und Patienten. Für unbekannte Transaktionen gibt es keine Beteiligten.
+func (node *Node) authorizeTransaction(w http.ResponseWriter, r *http.Request, status *client.TransactionStatus) bool {
+	var owners []string
+	if status != nil {
+		owners = []string{blockchain.KeyID(status.Transaction.Doctor), blockchain.KeyID(status.Transaction.Patient)}
+	}
+	_, ok := node.Auth.Authorize(w, r, readerRoles, owners...)
+	return ok
+}
+
+// GetTransactionHandler liefert nur bereits in Blöcke aufgenommene Transaktionen, Client Nodes kennen keinen Pool
+func (node *Node) GetTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	status, exists := node.LookupTransaction(strings.ToLower(r.PathValue("hash")))
+	if !node.authorizeTransaction(w, r, status) {
+		return
+	}
+	if !exists {
+		http.Error(w, "transaction not found", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(blockchainData)
+	json.NewEncoder(w).Encode(status)
 }
 
-func (authorityNode *AuthorityNode) AddTransactionHandler(w http.ResponseWriter, r *http.Request) {
-	var transaction blockchain.Transaction
+// GetTransactionHandler berücksichtigt zusätzlich die noch wartenden Transaktionen im Pool
+func (a *AuthorityNode) GetTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	txHash := strings.ToLower(r.PathValue("hash"))
 
-	// Dekodiere die Transaktionsdaten aus der Anfrage
-	err := json.NewDecoder(r.Body).Decode(&transaction)
-	if err != nil {
-		http.Error(w, "failed to decode transaction data", http.StatusBadRequest)
+	a.mutex.Lock()
+	status, exists := a.LookupTransaction(txHash)
+	if !exists {
+		if tx, pending := a.TransactionPool.Get(txHash); pending {
+			status, exists = &client.TransactionStatus{Status: client.TransactionStatusPending, Transaction: tx}, true
+		}
+	}
+	a.mutex.Unlock()
+
+	if !a.authorizeTransaction(w, r, status) {
+		return
+	}
+	if !exists {
+		http.Error(w, "transaction not found", http.StatusNotFound)
 		return
 	}
 
-	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to create transaction: %v", err), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (authorityNode *AuthorityNode) AddTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	var transaction blockchain.Transaction
+
+	// Dekodiere die Transaktionsdaten aus der Anfrage, unbekannte Felder deuten auf ein anderes Format hin
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, auth.MaxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&transaction); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode transaction data: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	// Füge die validierte und signierte Transaktion zum Pool hinzu
+	// AddTransaction prüft die Transaktion vollständig, bevor sie in den Pool kommt
 	if err := authorityNode.AddTransaction(&transaction); err != nil {
-		http.Error(w, fmt.Sprintf("failed to add transaction to pool: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("failed to add transaction to pool: %v", err), httpStatusFromAdmissionError(err))
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -92,6 +140,59 @@ func (authorityNode *AuthorityNode) AddTransactionHandler(w http.ResponseWriter,
 	w.Write([]byte("Transaction added to pool successfully"))
 }
 
+// AddTransactionsHandler nimmt mehrere Transaktionen auf einmal auf und meldet das Ergebnis je Transaktion
+func (authorityNode *AuthorityNode) AddTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	var batch client.TransactionBatch
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, auth.MaxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&batch); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode transaction batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(batch.Transactions) == 0 || len(batch.Transactions) > client.MaxBatchSize {
+		http.Error(w, fmt.Sprintf("batch must contain between 1 and %d transactions", client.MaxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	response := client.BatchResponse{Results: make([]client.BatchResult, 0, len(batch.Transactions))}
+	for i, transaction := range batch.Transactions {
+		result := client.BatchResult{Index: i, Status: http.StatusOK}
+		if transaction == nil {
+			result.Status, result.Error = http.StatusBadRequest, "transaction is required"
+			response.Results = append(response.Results, result)
+			continue
+		}
+
+		result.Hash = hex.EncodeToString(transaction.Hash)
+		if err := authorityNode.AddTransaction(transaction); err != nil {
+			result.Status, result.Error = httpStatusFromAdmissionError(err), err.Error()
+		} else {
+			response.Accepted++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// httpStatusFromAdmissionError übersetzt die Ablehnungsgründe von AddTransaction in HTTP-Statuscodes
+func httpStatusFromAdmissionError(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidTransaction):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrDoctorUnauthorized), errors.Is(err, ErrUrgentUnauthorized):
+		return http.StatusForbidden
+	case errors.Is(err, ErrNonceUsed), errors.Is(err, ErrNonceGap), errors.Is(err, blockchain.ErrPoolDuplicate):
+		return http.StatusConflict
+	case errors.Is(err, blockchain.ErrPoolFull), errors.Is(err, blockchain.ErrPoolDoctorLimit):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func (authorityNode *AuthorityNode) CreateBlockHandler(w http.ResponseWriter, r *http.Request) {
 	block, err := authorityNode.CreateBlock()
 	if err != nil {
@@ -111,33 +212,20 @@ func (authorityNode *AuthorityNode) CreateBlockHandler(w http.ResponseWriter, r
 }
 
 func (authorityNode *AuthorityNode) SyncHandler(w http.ResponseWriter, r *http.Request) {
-	var syncRequest SyncRequest
+	var syncRequest client.SyncRequest
 	if err := json.NewDecoder(r.Body).Decode(&syncRequest); err != nil {
 		http.Error(w, "failed to decode sync request", http.StatusBadRequest)
 		return
 	}
 
-	var syncBlocks []*blockchain.Block
-	if syncRequest.LastBlockHash == "" {
-		// Client hat keine Blöcke, sende die gesamte Blockchain
-		syncBlocks = authorityNode.Blockchain.Blocks
-	} else {
-		// Suche nach dem Block mit dem gegebenen Hash
-		syncStartIndex := -1
-		for i, block := range authorityNode.Blockchain.Blocks {
-			if fmt.Sprintf("%x", block.Hash) == syncRequest.LastBlockHash {
-				syncStartIndex = i + 1
-				break
-			}
-		}
-		if syncStartIndex == -1 {
-			http.Error(w, "block not found", http.StatusNotFound)
-			return
-		}
-		syncBlocks = authorityNode.Blockchain.Blocks[syncStartIndex:]
+	// Ohne Hash hat der Client keine Blöcke und erhält die gesamte Blockchain
+	syncBlocks, ok := authorityNode.Blockchain.BlocksSince(syncRequest.LastBlockHash)
+	if !ok {
+		http.Error(w, "block not found", http.StatusNotFound)
+		return
 	}
 
-	syncResponse := SyncResponse{Blocks: syncBlocks}
+	syncResponse := client.SyncResponse{Blocks: syncBlocks}
 	responseBody, err := json.Marshal(syncResponse)
 	if err != nil {
 		http.Error(w, "failed to serialize sync response", http.StatusInternalServerError)
@@ -149,10 +237,35 @@ func (authorityNode *AuthorityNode) SyncHandler(w http.ResponseWriter, r *http.R
 	w.Write(responseBody)
 }
 
+func (a *AuthorityNode) GetNonceHandler(w http.ResponseWriter, r *http.Request) {
+	doctorID := r.URL.Query().Get("doctorID")
+	if doctorID == "" {
+		http.Error(w, "doctorID is required", http.StatusBadRequest)
+		return
+	}
+
+	doctor, err := base64.URLEncoding.DecodeString(doctorID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode doctorID: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := a.Auth.Authorize(w, r, operatorRoles, blockchain.KeyID(doctor)); !ok {
+		return
+	}
+
+	a.mutex.Lock()
+	nonce := a.NextNonce(doctor)
+	a.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(client.NonceResponse{Nonce: nonce})
+}
+
 func (a *AuthorityNode) GetPublicKeyHandler(w http.ResponseWriter, r *http.Request) {
-	publicKey := utils.SerializePublicKey(&a.PrivateKey.PublicKey)
-	response := map[string]string{
-		"publicKey": base64.StdEncoding.EncodeToString(publicKey),
+	publicKey := utils.SerializePublicKey(a.Signer.PublicKey())
+	response := client.PublicKeyResponse{
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -164,7 +277,11 @@ func (authorityNode *AuthorityNode) GetTransactionPoolHandler(w http.ResponseWri
 	defer authorityNode.mutex.Unlock()
 
 	// Serialisiere den Transaktionspool
-	poolData, err := json.Marshal(authorityNode.TransactionPool.Transactions)
+	pool := make(map[string]*blockchain.Transaction)
+	for _, tx := range authorityNode.TransactionPool.GetTransactionsFromPool() {
+		pool[hex.EncodeToString(tx.Hash)] = tx
+	}
+	poolData, err := json.Marshal(pool)
 	if err != nil {
 		http.Error(w, "failed to serialize transaction pool", http.StatusInternalServerError)
 		return
@@ -178,14 +295,44 @@ func (authorityNode *AuthorityNode) GetTransactionPoolHandler(w http.ResponseWri
 
 func (a *AuthorityNode) SetupAuthorityNodeRoutes() {
 	a.SetupNodeRoutes()
-	http.HandleFunc("/addTransaction", a.AddTransactionHandler)
-	http.HandleFunc("/createBlock", a.CreateBlockHandler)
-	http.HandleFunc("/getPatientTransactions", a.GetPatientTransactionsHandler)
-	http.HandleFunc("/getTransactionPool", a.GetTransactionPoolHandler)
-	http.HandleFunc("/sync", a.SyncHandler)
-	http.HandleFunc("/getPublicKey", a.GetPublicKeyHandler)
+	a.Mux.HandleFunc("/addTransaction", a.AddTransactionHandler)
+	a.Mux.HandleFunc("POST /v1/transactions:batch", a.AddTransactionsHandler)
+	a.Mux.HandleFunc("/createBlock", a.require(a.CreateBlockHandler, operatorRoles...))
+	a.Mux.HandleFunc("/getTransactionPool", a.require(a.GetTransactionPoolHandler, operatorRoles...))
+	a.Mux.HandleFunc("/sync", a.require(a.SyncHandler, readerRoles...))
+	a.Mux.HandleFunc("/getPublicKey", a.GetPublicKeyHandler)
+	a.Mux.HandleFunc("/getNonce", a.GetNonceHandler)
+	a.Mux.HandleFunc("GET /tx/{hash}", a.GetTransactionHandler)
+	a.Mux.HandleFunc("GET /v1/events", a.require(a.EventsHandler, readerRoles...))
 }
 
+func (node *Node) ReindexHandler(w http.ResponseWriter, r *http.Request) {
+	if err := node.Blockchain.Reindex(); err != nil {
+		http.Error(w, fmt.Sprintf("failed to rebuild indexes: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	stats := node.Blockchain.Stats()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(client.ReindexResult{
+		Blocks:       stats.Blocks,
+		Transactions: stats.Transactions,
+		Patients:     stats.Patients,
+		Doctors:      stats.Doctors,
+	})
+}
+
+// SetupNodeRoutes registriert die lesenden Endpunkte, die jeder Node aus seiner synchronisierten Blockchain bedient
 func (node *Node) SetupNodeRoutes() {
-	http.HandleFunc("/getBlockchain", node.GetBlockchainHandler)
+	node.SetupV1Routes()
+	node.SetupWebhookRoutes()
+	node.SetupAuthRoutes()
+	node.Mux.HandleFunc("/getPatientTransactions", node.GetPatientTransactionsHandler)
+	node.Mux.HandleFunc("POST /reindex", node.require(node.ReindexHandler, operatorRoles...))
+}
+
+func (node *Node) SetupClientNodeRoutes() {
+	node.SetupNodeRoutes()
+	node.Mux.HandleFunc("GET /tx/{hash}", node.GetTransactionHandler)
+	node.Mux.HandleFunc("GET /v1/events", node.require(node.EventsHandler, readerRoles...))
 }
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+)
+
+// Rollen mit Zugriff auf betriebliche Endpunkte bzw. auf die gesamte Blockchain
+var (
+	operatorRoles = []auth.Role{auth.RoleOperator}
+	readerRoles   = []auth.Role{auth.RoleOperator, auth.RoleAuditor}
+)
+
+// require lässt nur Aufrufer mit einer der Rollen zum Handler durch (ohne aktivierte Authentifizierung alle)
+func (node *Node) require(handler http.HandlerFunc, roles ...auth.Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := node.Auth.Authorize(w, r, roles); ok {
+			handler(w, r)
+		}
+	}
+}
+
+// ChallengeHandler gibt eine Challenge aus, mit der ein Schlüsselinhaber (z.B. ein Patient) seinen Besitz beweist
+func (node *Node) ChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	if node.Auth == nil {
+		http.Error(w, "authentication is not enabled on this node", http.StatusNotFound)
+		return
+	}
+
+	var request client.ChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid challenge request", http.StatusBadRequest)
+		return
+	}
+
+	challenge, expiresAt, err := node.Auth.IssueChallenge(request.KeyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(client.ChallengeResponse{Challenge: challenge, ExpiresAt: expiresAt.Unix()})
+}
+
+// TokenHandler löst eine signierte Challenge gegen ein Session-Token ein
+func (node *Node) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if node.Auth == nil {
+		http.Error(w, "authentication is not enabled on this node", http.StatusNotFound)
+		return
+	}
+
+	var request client.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid token request", http.StatusBadRequest)
+		return
+	}
+
+	token, expiresAt, err := node.Auth.RedeemChallenge(request.KeyID, request.Challenge, request.Signature)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(client.TokenResponse{Token: token, ExpiresAt: expiresAt.Unix()})
+}
+
+func (node *Node) SetupAuthRoutes() {
+	node.Mux.HandleFunc("POST /v1/auth/challenge", node.ChallengeHandler)
+	node.Mux.HandleFunc("POST /v1/auth/token", node.TokenHandler)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+)
+
+const (
+	defaultBlockPageSize = 20
+	maxBlockPageSize     = 100
+)
+
+// ListBlocksHandler liefert die Blockheader seitenweise: /v1/blocks?from=<cursor>&limit=<n>
+func (node *Node) ListBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	from, err := parseUintParam(r, "from", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := parseUintParam(r, "limit", defaultBlockPageSize)
+	if err != nil || limit == 0 || limit > maxBlockPageSize {
+		http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxBlockPageSize), http.StatusBadRequest)
+		return
+	}
+
+	blocks := node.Blockchain.BlockRange(from, int(limit))
+
+	page := client.BlockPage{Blocks: make([]*blockchain.BlockHeader, 0, len(blocks))}
+	for _, block := range blocks {
+		page.Blocks = append(page.Blocks, block.Header())
+	}
+
+	// Blöcke sind unveränderlich, die Seite ändert sich nur, solange sie noch nicht voll ist oder als
+	// letzte Seite noch keinen Cursor hat. Der Cursor gehört daher mit ins ETag.
+	etag := fmt.Sprintf(`"blocks-%d-%d"`, from, limit)
+	if len(blocks) > 0 {
+		last := blocks[len(blocks)-1]
+		if uint64(len(blocks)) == limit && last.ID+1 < uint64(node.Blockchain.Len()) {
+			page.NextCursor = strconv.FormatUint(last.ID+1, 10)
+		}
+		etag = fmt.Sprintf(`"blocks-%d-%d-%x-%s"`, from, limit, last.Hash, page.NextCursor)
+	}
+
+	writeCachedJSON(w, r, etag, page)
+}
+
+// GetBlockHandler liefert einen vollständigen Block anhand seiner ID, seines Hashes oder "latest"
+func (node *Node) GetBlockHandler(w http.ResponseWriter, r *http.Request) {
+	ref := r.PathValue("ref")
+
+	var block *blockchain.Block
+	var exists bool
+	switch {
+	case ref == "latest":
+		block = node.Blockchain.LatestBlock()
+		exists = block != nil
+	case isBlockHash(ref):
+		block, exists = node.Blockchain.GetBlockByHash(strings.ToLower(ref))
+	default:
+		id, err := strconv.ParseUint(ref, 10, 64)
+		if err != nil {
+			http.Error(w, "block reference must be an ID, a hash or \"latest\"", http.StatusBadRequest)
+			return
+		}
+		block, exists = node.Blockchain.GetBlockByID(id)
+	}
+
+	if !exists {
+		http.Error(w, "block not found", http.StatusNotFound)
+		return
+	}
+
+	writeCachedJSON(w, r, fmt.Sprintf(`"%x"`, block.Hash), block)
+}
+
+func (node *Node) ChainInfoHandler(w http.ResponseWriter, r *http.Request) {
+	tip := node.Blockchain.LatestBlock()
+	if tip == nil {
+		http.Error(w, "blockchain not synchronized yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeCachedJSON(w, r, fmt.Sprintf(`"info-%x"`, tip.Hash), node.chainInfo())
+}
+
+// chainInfo fasst Höhe, Tip und Genesis zusammen, bei leerer Blockchain ist nur die Chain-ID gesetzt
+func (node *Node) chainInfo() client.ChainInfo {
+	info := client.ChainInfo{ChainID: node.Blockchain.ChainID, BlockPolicy: node.blockPolicy()}
+
+	tip := node.Blockchain.LatestBlock()
+	if tip == nil {
+		return info
+	}
+
+	info.Height = tip.ID
+	info.TipHash = hex.EncodeToString(tip.Hash)
+	info.TipTimestamp = tip.Timestamp
+	genesis, _ := node.Blockchain.GetBlockByID(0)
+	info.GenesisHash = hex.EncodeToString(genesis.Hash)
+	return info
+}
+
+// writeCachedJSON setzt das ETag und beantwortet passende If-None-Match-Anfragen mit 304
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, etag string, value interface{}) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			if strings.TrimSpace(candidate) == etag || strings.TrimSpace(candidate) == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, "failed to serialize response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func parseUintParam(r *http.Request, name string, defaultValue uint64) (uint64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return parsed, nil
+}
+
+func isBlockHash(ref string) bool {
+	if len(ref) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(ref)
+	return err == nil
+}
+
+func (node *Node) SetupV1Routes() {
+	node.Mux.HandleFunc("GET /v1/blocks", node.require(node.ListBlocksHandler, readerRoles...))
+	node.Mux.HandleFunc("GET /v1/blocks/{ref}", node.require(node.GetBlockHandler, readerRoles...))
+	node.Mux.HandleFunc("GET /v1/chain/info", node.ChainInfoHandler)
+}
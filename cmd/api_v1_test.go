@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// addTestBlocks erzeugt count Blöcke mit je einer Transaktion des Arztes
+func addTestBlocks(t *testing.T, authorityNode *AuthorityNode, doctor, patient utils.Signer, count int) []*blockchain.Transaction {
+	var transactions []*blockchain.Transaction
+	for range count {
+		tx, err := blockchain.NewTransaction(testChainID, authorityNode.NextNonce(utils.SerializePublicKey(doctor.PublicKey())), "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+		require.NoError(t, err)
+		require.NoError(t, authorityNode.AddTransaction(tx))
+		_, err = authorityNode.CreateBlock()
+		require.NoError(t, err)
+		transactions = append(transactions, tx)
+	}
+	return transactions
+}
+
+func TestListBlocksPagesAndRevalidates(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	authorityNode.SetupAuthorityNodeRoutes()
+	doctor, patient := newTestSigner(t), newTestSigner(t)
+	addTestBlocks(t, authorityNode, doctor, patient, 4)
+
+	list := func(query, etag string) (*httptest.ResponseRecorder, client.BlockPage) {
+		request := httptest.NewRequest(http.MethodGet, "/v1/blocks?"+query, nil)
+		if etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+		recorder := httptest.NewRecorder()
+		authorityNode.Mux.ServeHTTP(recorder, request)
+
+		var page client.BlockPage
+		if recorder.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&page))
+		}
+		return recorder, page
+	}
+
+	// Genesis und vier Blöcke: 0-1, 2-3, 4
+	recorder, page := list("from=0&limit=2", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, page.Blocks, 2)
+	require.Equal(t, "2", page.NextCursor)
+
+	_, page = list("from="+page.NextCursor+"&limit=2", "")
+	require.Equal(t, []uint64{2, 3}, []uint64{page.Blocks[0].ID, page.Blocks[1].ID})
+	require.Equal(t, "4", page.NextCursor)
+
+	// Die volle Seite 3-4 ist zunächst die letzte
+	recorder, page = list("from=3&limit=2", "")
+	require.Empty(t, page.NextCursor)
+	etag := recorder.Header().Get("ETag")
+	recorder, _ = list("from=3&limit=2", etag)
+	require.Equal(t, http.StatusNotModified, recorder.Code)
+
+	// Nach einem neuen Block hat dieselbe Seite einen Cursor und damit ein anderes ETag
+	addTestBlocks(t, authorityNode, doctor, patient, 1)
+	recorder, page = list("from=3&limit=2", etag)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "5", page.NextCursor)
+	require.NotEqual(t, etag, recorder.Header().Get("ETag"))
+
+	recorder, _ = list("limit=0", "")
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
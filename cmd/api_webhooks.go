@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/webhook"
+)
+
+// RegisterWebhookHandler registriert einen Webhook für neue Einträge eines Patienten
+func (node *Node) RegisterWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Der Body wird vor der Prüfung der Signatur gelesen und für Authorize wiederhergestellt
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, auth.MaxRequestBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "webhook registration too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid webhook registration", http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var registration client.WebhookRegistration
+	if err := json.Unmarshal(body, &registration); err != nil {
+		http.Error(w, "invalid webhook registration", http.StatusBadRequest)
+		return
+	}
+
+	// Patienten dürfen nur Webhooks für ihre eigenen Einträge anlegen
+	if _, ok := node.Auth.Authorize(w, r, operatorRoles, registration.PatientID); !ok {
+		return
+	}
+
+	registered, err := node.Webhooks.Register(registration.URL, registration.PatientID, registration.Secret)
+	if errors.Is(err, webhook.ErrPrivateTarget) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(registered)
+}
+
+// ListWebhooksHandler liefert Operatoren alle Webhooks, allen anderen nur die eigenen
+func (node *Node) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := node.Auth.Authorize(w, r, []auth.Role{auth.RolePatient})
+	if !ok {
+		return
+	}
+
+	webhooks := node.Webhooks.List()
+	if !node.Auth.Allowed(identity, operatorRoles) {
+		own := make([]*webhook.Webhook, 0)
+		for _, registered := range webhooks {
+			if registered.PatientID == identity.KeyID {
+				own = append(own, registered)
+			}
+		}
+		webhooks = own
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// authorizeWebhook prüft, ob der Aufrufer den Webhook verwalten darf, und schreibt andernfalls die Fehlerantwort
+func (node *Node) authorizeWebhook(w http.ResponseWriter, r *http.Request) bool {
+	registered, exists := node.Webhooks.Get(r.PathValue("id"))
+	if !exists {
+		// Ohne Webhook gibt es keinen Eigentümer, nur Operatoren erfahren, dass er fehlt
+		if _, ok := node.Auth.Authorize(w, r, operatorRoles); ok {
+			http.Error(w, "webhook not found", http.StatusNotFound)
+		}
+		return false
+	}
+
+	_, ok := node.Auth.Authorize(w, r, operatorRoles, registered.PatientID)
+	return ok
+}
+
+func (node *Node) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !node.authorizeWebhook(w, r) {
+		return
+	}
+
+	removed, err := node.Webhooks.Remove(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to remove webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (node *Node) GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if !node.authorizeWebhook(w, r) {
+		return
+	}
+
+	deliveries, exists := node.Webhooks.Deliveries(r.PathValue("id"))
+	if !exists {
+		http.Error(w, "webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (node *Node) SetupWebhookRoutes() {
+	node.Mux.HandleFunc("POST /v1/webhooks", node.RegisterWebhookHandler)
+	node.Mux.HandleFunc("GET /v1/webhooks", node.ListWebhooksHandler)
+	node.Mux.HandleFunc("DELETE /v1/webhooks/{id}", node.DeleteWebhookHandler)
+	node.Mux.HandleFunc("GET /v1/webhooks/{id}/deliveries", node.GetWebhookDeliveriesHandler)
+}
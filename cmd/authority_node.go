@@ -1,60 +1,112 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"crypto/ecdsa"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
 )
 
+// Gründe, aus denen AddTransaction eine Transaktion ablehnt, mit errors.Is prüfbar.
+// Andere Fehler sind Fehler des Nodes, nicht der Transaktion.
+var (
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	ErrDoctorUnauthorized = errors.New("doctor is not authorized to submit transactions")
+	ErrUrgentUnauthorized = errors.New("doctor is not authorized to submit urgent transactions")
+	ErrNonceUsed          = errors.New("nonce already used")
+	ErrNonceGap           = errors.New("nonce out of order")
+)
+
+// ErrPoolEmpty meldet CreateBlock, wenn nach dem Verwerfen abgelaufener und ungültiger Transaktionen
+// keine mehr warten
+var ErrPoolEmpty = errors.New("not enough transactions to create a new block")
+
+// gcmNonceSize ist die Länge der AES-GCM-Nonce in EncryptedData
+const gcmNonceSize = 12
+
 type AuthorityNode struct {
-	PrivateKey           *ecdsa.PrivateKey
+	Signer               utils.Signer                // Schlüssel der Authority (Speicher, Keystore oder HSM)
 	TransactionPool      *blockchain.TransactionPool // Verwende den TransactionPool
 	*Node                                            // Vererbung von Node
 	LastBlockTimestamp   int64                       // Zeitstempel des letzten Blocks
 	BlockCreationTrigger chan struct{}               // Kanal zum Auslösen der Blockerstellung
+	PoolEvents           *PoolEventBroker            // Verteilt Pool-Änderungen an die Event-Streams
 	mutex                sync.Mutex                  // Mutex zur Synchronisierung der Transaktionsverarbeitung
 }
 
-// Erstellt einen neuen AuthorityNode
-func NewAuthorityNode(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey) *AuthorityNode {
-	node := NewNode(privateKey, "localhost:8080")
+// Erstellt einen neuen AuthorityNode auf Basis einer (ggf. aus dem Store geladenen) Blockchain,
+// der Blöcke gemäß der Policy erzeugt
+func NewAuthorityNode(bc *blockchain.Blockchain, signer utils.Signer, policy blockchain.BlockPolicy) (*AuthorityNode, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Der Authority Node synchronisiert sich mit niemandem
+	node := NewNode(bc, "")
+	node.BlockPolicy = &policy
 
 	authorityNode := &AuthorityNode{
-		PrivateKey:           privateKey,
-		TransactionPool:      blockchain.NewTransactionPool(),
+		Signer:               signer,
+		TransactionPool:      blockchain.NewTransactionPool(blockchain.DefaultPoolLimits()),
 		Node:                 node,
 		LastBlockTimestamp:   time.Now().Unix(),
-		BlockCreationTrigger: make(chan struct{}),
+		BlockCreationTrigger: make(chan struct{}, 1),
+		PoolEvents:           NewPoolEventBroker(),
 		mutex:                sync.Mutex{},
 	}
 
-	// Erstelle den Genesis-Block
-	authorityNode.Blockchain = blockchain.NewBlockchain(privateKey)
+	if len(bc.Blocks) == 0 {
+		// Erstelle den Genesis-Block
+		genesisBlock, err := blockchain.CreateGenesisBlock(bc.ChainID, signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create genesis block: %v", err)
+		}
+		if err := bc.AddBlock(genesisBlock); err != nil {
+			return nil, fmt.Errorf("failed to add genesis block: %v", err)
+		}
+	} else if err := bc.Verify([]*ecdsa.PublicKey{signer.PublicKey()}); err != nil {
+		// Eine gespeicherte Blockchain muss vollständig vom selben Authority-Schlüssel stammen
+		return nil, fmt.Errorf("stored blockchain does not belong to this authority: %v", err)
+	}
 
-	go authorityNode.StartBlockGenerator()
+	authorityNode.LastBlockTimestamp = bc.Blocks[len(bc.Blocks)-1].Timestamp
+	// Der Authority Node ist die Quelle der Blockchain und damit immer auf dem aktuellen Stand
+	node.markSynced()
 
-	return authorityNode
+	return authorityNode, nil
 }
 
 func (a *AuthorityNode) AddTransaction(transaction *blockchain.Transaction) error {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
+	if err := a.admit(transaction); err != nil {
+		return err
+	}
+
 	// Füge die Transaktion zum TransactionPool hinzu
 	if err := a.TransactionPool.AddTransactionToPool(transaction); err != nil {
-		return fmt.Errorf("error adding transaction to pool: %v", err)
+		return fmt.Errorf("error adding transaction to pool: %w", err)
 	}
-
-	// Überprüfe, ob die Anzahl der Transaktionen im Pool den Schwellenwert für die Blockerstellung erreicht
-	if len(a.TransactionPool.GetTransactionsFromPool()) >= 5 {
+	a.PoolEvents.Publish(client.PoolEvent{
+		Action:      client.PoolEventAdded,
+		Hash:        hex.EncodeToString(transaction.Hash),
+		Transaction: transaction,
+	})
+
+	// Ein voller Block oder ein dringender Eintrag wird sofort erzeugt (frühestens nach dem Mindestabstand),
+	// sonst spätestens nach MaxInterval
+	if transaction.Urgent || a.blockFull() {
 		select {
 		case a.BlockCreationTrigger <- struct{}{}:
 			fmt.Println("BlockCreationTrigger was signalled")
@@ -66,46 +118,141 @@ func (a *AuthorityNode) AddTransaction(transaction *blockchain.Transaction) erro
 	return nil
 }
 
-func (a *AuthorityNode) CreateBlock() (*blockchain.Block, error) {
-	// Sperre den Zugriff auf den TransactionPool
+// RestorePool nimmt die Transaktionen aus dem Journal wieder in den Pool auf und führt das Journal danach
+// weiter. Jede Transaktion wird erneut geprüft, bereits aufgenommene oder inzwischen ungültige werden verworfen.
+func (a *AuthorityNode) RestorePool(journal *blockchain.PoolJournal) error {
+	entries, err := journal.Load()
+	if err != nil {
+		return err
+	}
+
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	// Hole alle Transaktionen aus dem Transaktionspool
-	pendingTransactions := a.TransactionPool.GetTransactionsFromPool()
-	if len(pendingTransactions) < 1 {
-		return nil, fmt.Errorf("not enough transactions to create a new block")
-	}
-
-	// Validierung jeder Transaktion vor dem Hinzufügen zum Block
-	for _, tx := range pendingTransactions {
-		doctorPublicKey, err := utils.DeserializePublicKey(tx.Doctor)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't deserialize doctor public key: %v", err)
+	restored := 0
+	for _, entry := range entries {
+		err := a.admit(entry.Transaction)
+		if err == nil {
+			err = a.TransactionPool.Restore(entry)
 		}
-
-		err = tx.ValidateTransaction(doctorPublicKey)
 		if err != nil {
-			return nil, fmt.Errorf("invalid transaction in pool: %v", err)
+			fmt.Printf("Transaktion %x aus dem Journal verworfen: %v\n", entry.Transaction.Hash, err)
+			continue
 		}
+		restored++
+	}
+	if restored > 0 {
+		fmt.Printf("%d wartende Transaktionen aus dem Journal wiederhergestellt\n", restored)
+	}
+
+	return a.TransactionPool.UseJournal(journal)
+}
+
+// admit prüft, ob die Transaktion in den Pool aufgenommen werden darf. Der Aufrufer muss den Mutex halten.
+func (a *AuthorityNode) admit(transaction *blockchain.Transaction) error {
+	if err := a.validateTransaction(transaction); err != nil {
+		return err
+	}
+
+	// Replay-Schutz: nur die nächste freie Nonce des Arztes
+	expectedNonce := a.NextNonce(transaction.Doctor)
+	if transaction.Nonce < expectedNonce {
+		return fmt.Errorf("%w: %d (transaction already included or pending), expected %d", ErrNonceUsed, transaction.Nonce, expectedNonce)
+	}
+	if transaction.Nonce > expectedNonce {
+		return fmt.Errorf("%w: %d, expected %d", ErrNonceGap, transaction.Nonce, expectedNonce)
+	}
+	return nil
+}
+
+// validateTransaction prüft die Transaktion unabhängig vom Pool: Chain, Schlüssel, Hash, Signatur,
+// verschlüsselte Daten, Rolle des Arztes und Größe. Auch ohne Authentifizierung (Auth nil) wird alles
+// außer der Rolle geprüft.
+func (a *AuthorityNode) validateTransaction(transaction *blockchain.Transaction) error {
+	if transaction.ChainID != a.Blockchain.ChainID {
+		return fmt.Errorf("%w: chain ID mismatch: expected %q, got %q", ErrInvalidTransaction, a.Blockchain.ChainID, transaction.ChainID)
 	}
 
-	// Erstelle einen neuen Block mit den Transaktionen aus dem Pool
+	// Die Signatur weist den Arzt aus. Sie wird immer vor der Nonce geprüft, sonst könnte eine
+	// unsignierte Transaktion die nächste Nonce eines fremden Arztes belegen.
+	doctorKey, err := utils.DeserializePublicKey(transaction.Doctor)
+	if err != nil {
+		return fmt.Errorf("%w: invalid doctor public key: %v", ErrInvalidTransaction, err)
+	}
+	if err := transaction.ValidateTransaction(doctorKey, a.Blockchain.ChainID); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTransaction, err)
+	}
+
+	// Der Patient muss die Daten entschlüsseln können
+	if _, err := utils.DeserializePublicKey(transaction.Patient); err != nil {
+		return fmt.Errorf("%w: invalid patient public key: %v", ErrInvalidTransaction, err)
+	}
+	if len(transaction.EncryptedData.Ciphertext) == 0 || len(transaction.EncryptedData.Nonce) != gcmNonceSize {
+		return fmt.Errorf("%w: encrypted data must contain a ciphertext and a %d byte nonce", ErrInvalidTransaction, gcmNonceSize)
+	}
+
+	// Einreichen dürfen nur registrierte Ärzte
+	if a.Auth != nil && !a.Auth.Allowed(&auth.Identity{KeyID: blockchain.KeyID(transaction.Doctor)}, []auth.Role{auth.RoleDoctor}) {
+		return ErrDoctorUnauthorized
+	}
+	// Dringende Einträge dürfen nur Ärzte mit der Rolle emergency einreichen
+	if transaction.Urgent && a.Auth != nil && !a.Auth.Allowed(&auth.Identity{KeyID: blockchain.KeyID(transaction.Doctor)}, []auth.Role{auth.RoleEmergency}) {
+		return ErrUrgentUnauthorized
+	}
+
+	if size := blockchain.TransactionSize(transaction); size > a.BlockPolicy.MaxBlockBytes {
+		return fmt.Errorf("%w: size %d exceeds the maximum block size of %d bytes", ErrInvalidTransaction, size, a.BlockPolicy.MaxBlockBytes)
+	}
+	return nil
+}
+
+// NextNonce liefert die nächste Nonce des Arztes unter Berücksichtigung der wartenden Transaktionen.
+// Der Aufrufer muss den Mutex halten.
+func (a *AuthorityNode) NextNonce(doctor []byte) uint64 {
+	return a.Blockchain.NextNonce(doctor) + uint64(a.TransactionPool.CountFromDoctor(doctor))
+}
+
+// CreateBlock erzeugt einen Block aus den wartenden Transaktionen, höchstens so viele wie die Policy erlaubt
+func (a *AuthorityNode) CreateBlock() (*blockchain.Block, error) {
+	return a.createBlock(false)
+}
+
+func (a *AuthorityNode) createBlock(allowEmpty bool) (*blockchain.Block, error) {
+	// Sperre den Zugriff auf den TransactionPool
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.expirePool()
+	a.evictInvalid()
+	pendingTransactions := a.pendingTransactions()
+	if len(pendingTransactions) < 1 && !allowEmpty {
+		return nil, ErrPoolEmpty
+	}
+	pendingTransactions = a.BlockPolicy.SelectTransactions(pendingTransactions)
+	if len(pendingTransactions) < 1 && !allowEmpty {
+		return nil, fmt.Errorf("transaction exceeds the maximum block size")
+	}
+
+	// Erstelle einen neuen Block mit den Transaktionen aus dem Pool. Nur der Authority Node hängt
+	// Blöcke an, unter dem Mutex bleibt der letzte Block daher derselbe.
+	latestBlock := a.Blockchain.LatestBlock()
 	newBlock := &blockchain.Block{
-		ID:           uint64(len(a.Blockchain.Blocks) + 1),
-		PreviousHash: a.Blockchain.Blocks[len(a.Blockchain.Blocks)-1].Hash,
+		ID:           latestBlock.ID + 1,
+		PreviousHash: latestBlock.Hash,
 		Transactions: pendingTransactions,
 		Timestamp:    time.Now().Unix(),
 	}
 
 	// Berechne den Hash und signiere den Block
-	hash, err := newBlock.CalculateHash()
+	hash, err := newBlock.CalculateHash(a.Blockchain.ChainID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to calculate hash: %v", err)
 	}
 	newBlock.Hash = hash
 
-	newBlock.SignBlock(a.PrivateKey)
+	if err := newBlock.SignBlock(a.Signer); err != nil {
+		return nil, fmt.Errorf("failed to sign block: %v", err)
+	}
 
 	// Füge den Block zur Blockchain hinzu
 	if err := a.AddBlockToBlockchain(newBlock); err != nil {
@@ -116,20 +263,10 @@ func (a *AuthorityNode) CreateBlock() (*blockchain.Block, error) {
 	for _, tx := range pendingTransactions {
 		txHash := fmt.Sprintf("%x", tx.Hash)
 		a.TransactionPool.RemoveTransactionFromPool(txHash)
+		a.PoolEvents.Publish(client.PoolEvent{Action: client.PoolEventRemoved, Hash: txHash})
 	}
-
-	for _, tx := range newBlock.Transactions {
-		//patientHash := base64.StdEncoding.EncodeToString(tx.Patient)
-		patientHash := base64.URLEncoding.EncodeToString(tx.Patient)
-
-		if _, exists := a.Patients[patientHash]; !exists {
-			a.Patients[patientHash] = PatientData{
-				Transactions: make(map[string]*blockchain.Transaction),
-			}
-		}
-
-		a.Patients[patientHash].Transactions[base64.StdEncoding.EncodeToString(tx.Hash)] = tx
-		fmt.Println(tx.Patient)
+	if err := a.TransactionPool.Compact(); err != nil {
+		fmt.Printf("Error compacting the pool journal: %v\n", err)
 	}
 
 	return newBlock, nil
@@ -140,9 +277,9 @@ func (a *AuthorityNode) AddBlockToBlockchain(block *blockchain.Block) error {
 		return fmt.Errorf("failed to validate block: %v", err)
 	}
 
-	a.Blockchain.Blocks = append(a.Blockchain.Blocks, block)
-	hashString := fmt.Sprintf("%x", block.Hash)
-	a.Blockchain.BlockMap[hashString] = block
+	if err := a.Blockchain.AddBlock(block); err != nil {
+		return err
+	}
 
 	a.LastBlockTimestamp = block.Timestamp
 
@@ -151,45 +288,101 @@ func (a *AuthorityNode) AddBlockToBlockchain(block *blockchain.Block) error {
 }
 
 func (a *AuthorityNode) ValidateBlock(block *blockchain.Block) error {
-	// Erstelle eine temporäre Kopie des Blocks ohne Hash und Signatur
-	tempBlock := *block
-	tempBlock.Hash = nil
-	tempBlock.Signature = nil
+	// Hash, Signatur und Transaktionen werden domänengetrennt gegen die eigene Chain-ID geprüft
+	return block.ValidateBlock(a.Signer.PublicKey(), a.Blockchain.ChainID)
+}
 
-	// Berechne den Hash aus der temporären Blockkopie
-	blockBytes, err := json.Marshal(tempBlock)
-	if err != nil {
-		return fmt.Errorf("failed to serialize block: %v", err)
-	}
-	calculatedHash := sha256.Sum256(blockBytes)
+// pendingTransactions liefert den Pool in der Reihenfolge des Eingangs, damit die Transaktionen jedes Arztes
+// in der Reihenfolge ihrer Nonces im Block landen. Der Aufrufer muss den Mutex halten.
+func (a *AuthorityNode) pendingTransactions() []*blockchain.Transaction {
+	return a.TransactionPool.GetTransactionsFromPool()
+}
 
-	// Überprüfe, ob der berechnete Hash mit dem gespeicherten Hash übereinstimmt
-	if !bytes.Equal(calculatedHash[:], block.Hash) {
-		return fmt.Errorf("invalid block hash for block ID %d", block.ID)
+// expirePool verwirft die Transaktionen, deren TTL abgelaufen ist. Der Aufrufer muss den Mutex halten.
+func (a *AuthorityNode) expirePool() {
+	a.publishEvicted(a.TransactionPool.Expire())
+}
+
+// evictInvalid verwirft Transaktionen, die inzwischen ungültig sind (z.B. nach Entzug der Arztrolle oder
+// kleinerer Blockgröße), statt an ihnen die Blockerstellung scheitern zu lassen. Der Aufrufer muss den
+// Mutex halten.
+func (a *AuthorityNode) evictInvalid() {
+	for _, tx := range a.pendingTransactions() {
+		txHash := hex.EncodeToString(tx.Hash)
+		if _, pending := a.TransactionPool.Get(txHash); !pending {
+			// Bereits mit einer früheren Transaktion des Arztes verworfen
+			continue
+		}
+		if err := a.validateTransaction(tx); err != nil {
+			fmt.Printf("Invalid transaction %s in pool: %v\n", txHash, err)
+			a.publishEvicted(a.TransactionPool.Evict(txHash, blockchain.EvictInvalid))
+		}
 	}
+}
 
-	// Überprüfe, ob die Signatur gültig ist
-	if !utils.VerifySignature(&a.PrivateKey.PublicKey, block.Hash, block.Signature.R, block.Signature.S) {
-		return fmt.Errorf("invalid signature for transaction hash %x", block.Hash)
+// publishEvicted meldet die ohne Block entfernten Transaktionen mit ihrem Grund
+func (a *AuthorityNode) publishEvicted(evicted map[string]string) {
+	for _, txHash := range slices.Sorted(maps.Keys(evicted)) {
+		fmt.Printf("Transaction %s evicted from the pool: %s\n", txHash, evicted[txHash])
+		a.PoolEvents.Publish(client.PoolEvent{Action: client.PoolEventEvicted, Hash: txHash, Reason: evicted[txHash]})
 	}
+}
 
-	return nil
+// blockFull meldet, ob die wartenden Transaktionen einen vollen Block ergeben. Der Aufrufer muss den Mutex halten.
+func (a *AuthorityNode) blockFull() bool {
+	pendingTransactions := a.pendingTransactions()
+	return len(a.BlockPolicy.SelectTransactions(pendingTransactions)) < len(pendingTransactions) ||
+		len(pendingTransactions) >= a.BlockPolicy.MaxTransactions
+}
+
+// nextBlockDelay berechnet, wann der nächste Block fällig ist. ok ist false, solange ohne neue
+// Transaktionen kein Block fällig wird.
+func (a *AuthorityNode) nextBlockDelay() (delay time.Duration, ok bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.expirePool()
+	policy := a.BlockPolicy
+	since := time.Since(time.Unix(a.LastBlockTimestamp, 0))
+
+	switch {
+	case a.blockFull() || a.TransactionPool.HasUrgent():
+		return max(time.Duration(policy.MinInterval)-since, 0), true
+	case a.TransactionPool.Len() > 0 || policy.EmptyBlocks:
+		return max(time.Duration(policy.MaxInterval)-since, time.Duration(policy.MinInterval)-since, 0), true
+	default:
+		return 0, false
+	}
 }
 
-// check if conditions are met every 5th minute with sleep
-func (a *AuthorityNode) StartBlockGenerator() {
+// StartBlockGenerator erzeugt Blöcke gemäß der Policy: sofort bei vollem Block (frühestens nach MinInterval),
+// sonst spätestens MaxInterval nach dem letzten Block, ohne Transaktionen nur mit EmptyBlocks.
+// Ein laufender Block wird noch fertiggestellt, danach endet der Generator mit ctx.
+func (a *AuthorityNode) StartBlockGenerator(ctx context.Context) {
+	failed := false
 	for {
+		delay, ok := a.nextBlockDelay()
+		if failed {
+			// Nach einem Fehler nicht sofort erneut versuchen
+			delay = max(delay, time.Duration(a.BlockPolicy.MaxInterval))
+		}
+
+		var timer <-chan time.Time
+		if ok {
+			timer = time.After(delay)
+		}
+
 		select {
-		case <-time.After(5 * time.Minute):
-			// Alle 5 Minuten versuchen, einen neuen Block zu erstellen
-			if _, err := a.CreateBlock(); err != nil {
+		case <-timer:
+			_, err := a.createBlock(a.BlockPolicy.EmptyBlocks)
+			if err != nil {
 				fmt.Printf("Error creating a block: %v\n", err)
 			}
+			failed = err != nil
 		case <-a.BlockCreationTrigger:
-			// Wenn eine neue Transaktion hinzugefügt wurde, versuche, einen Block zu erstellen
-			if _, err := a.CreateBlock(); err != nil {
-				fmt.Printf("Error creating a block: %v\n", err)
-			}
+			// Neue Transaktionen, Fälligkeit neu berechnen
+		case <-ctx.Done():
+			return
 		}
 	}
 }
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestAuthorityNode(t *testing.T) *AuthorityNode {
+	authorityNode, err := NewAuthorityNode(blockchain.NewEmptyBlockchain(testChainID), newTestSigner(t), blockchain.DefaultBlockPolicy())
+	require.NoError(t, err)
+	return authorityNode
+}
+
+// Ohne Rollen (Auth nil) darf eine unsignierte Transaktion die Nonce eines fremden Arztes nicht belegen
+func TestAddTransactionRejectsUnsignedTransaction(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	forged, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Forged", "", newTestSigner(t), patient.PublicKey())
+	require.NoError(t, err)
+	forged.Doctor = utils.SerializePublicKey(doctor.PublicKey())
+	forged.Signature = nil
+	forged.Hash, err = forged.CalculateHash()
+	require.NoError(t, err)
+	require.Error(t, authorityNode.AddTransaction(forged))
+
+	tx, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, authorityNode.AddTransaction(tx))
+
+	block, err := authorityNode.CreateBlock()
+	require.NoError(t, err)
+	require.Len(t, block.Transactions, 1)
+}
+
+// Die Ablehnungsgründe sind unterscheidbar, damit HTTP und gRPC passende Statuscodes melden
+func TestAddTransactionRejectionReasons(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	wrongChain, err := blockchain.NewTransaction("other-chain", 0, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	err = authorityNode.AddTransaction(wrongChain)
+	require.ErrorIs(t, err, ErrInvalidTransaction)
+	require.Equal(t, codes.InvalidArgument, status.Code(grpcStatusFromAdmissionError(err)))
+
+	gap, err := blockchain.NewTransaction(testChainID, 1, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	err = authorityNode.AddTransaction(gap)
+	require.ErrorIs(t, err, ErrNonceGap)
+	require.Equal(t, codes.FailedPrecondition, status.Code(grpcStatusFromAdmissionError(err)))
+
+	tx, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, authorityNode.AddTransaction(tx))
+	err = authorityNode.AddTransaction(tx)
+	require.ErrorIs(t, err, ErrNonceUsed)
+	require.Equal(t, codes.AlreadyExists, status.Code(grpcStatusFromAdmissionError(err)))
+
+	require.Equal(t, codes.Internal, status.Code(grpcStatusFromAdmissionError(errors.New("disk full"))))
+}
+
+// Volle Pools lehnen mit eigenem Grund ab, ohne die Nonce des Arztes zu belegen
+func TestAddTransactionRespectsPoolLimits(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	authorityNode.TransactionPool.Limits = blockchain.PoolLimits{MaxTransactions: 10, MaxPerDoctor: 1}
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	for nonce := range uint64(2) {
+		tx, err := blockchain.NewTransaction(testChainID, nonce, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+		require.NoError(t, err)
+		err = authorityNode.AddTransaction(tx)
+		if nonce == 0 {
+			require.NoError(t, err)
+			continue
+		}
+		require.ErrorIs(t, err, blockchain.ErrPoolDoctorLimit)
+		require.Equal(t, codes.ResourceExhausted, status.Code(grpcStatusFromAdmissionError(err)))
+	}
+	require.Equal(t, uint64(1), authorityNode.NextNonce(utils.SerializePublicKey(doctor.PublicKey())))
+}
+
+// Nach einem Absturz kommen nur die noch nicht aufgenommenen und weiterhin gültigen Transaktionen zurück
+func TestRestorePoolRevalidatesJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pool.journal")
+	bc := blockchain.NewEmptyBlockchain(testChainID)
+	signer := newTestSigner(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	authorityNode, err := NewAuthorityNode(bc, signer, blockchain.DefaultBlockPolicy())
+	require.NoError(t, err)
+	journal, err := blockchain.OpenPoolJournal(path)
+	require.NoError(t, err)
+	require.NoError(t, authorityNode.RestorePool(journal))
+
+	var transactions []*blockchain.Transaction
+	for nonce := range uint64(3) {
+		tx, err := blockchain.NewTransaction(testChainID, nonce, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+		require.NoError(t, err)
+		transactions = append(transactions, tx)
+	}
+	require.NoError(t, authorityNode.AddTransaction(transactions[0]))
+	_, err = authorityNode.CreateBlock()
+	require.NoError(t, err)
+	require.NoError(t, authorityNode.AddTransaction(transactions[1]))
+	require.NoError(t, authorityNode.AddTransaction(transactions[2]))
+
+	// Ein bereits aufgenommener Eintrag, der nach dem Kompaktieren wieder im Journal steht, wird verworfen
+	restored := blockchain.NewTransactionPool(blockchain.DefaultPoolLimits())
+	require.NoError(t, restored.Restore(&blockchain.PoolEntry{Transaction: transactions[0], AddedAt: time.Now()}))
+	for _, entry := range authorityNode.TransactionPool.Entries() {
+		require.NoError(t, restored.Restore(entry))
+	}
+	require.NoError(t, journal.Compact(restored.Entries()))
+
+	// Absturz ohne Stop: ein neuer Authority Node auf derselben Chain
+	restarted, err := NewAuthorityNode(bc, signer, blockchain.DefaultBlockPolicy())
+	require.NoError(t, err)
+	reopened, err := blockchain.OpenPoolJournal(path)
+	require.NoError(t, err)
+	defer reopened.Close()
+	require.NoError(t, restarted.RestorePool(reopened))
+	require.Equal(t, transactions[1:], restarted.TransactionPool.GetTransactionsFromPool())
+
+	block, err := restarted.CreateBlock()
+	require.NoError(t, err)
+	require.Len(t, block.Transactions, 2)
+	entries, err := reopened.Load()
+	require.NoError(t, err)
+	require.Empty(t, entries, "mined transactions must be compacted away")
+}
+
+// Eine ungültige Transaktion im Pool wird bei der Blockerstellung verworfen, statt sie zu blockieren
+func TestCreateBlockEvictsInvalidTransactions(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	doctor, other := newTestSigner(t), newTestSigner(t)
+	patient := newTestSigner(t)
+
+	invalid, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Routine checkup", "", other, patient.PublicKey())
+	require.NoError(t, err)
+	later, err := blockchain.NewTransaction(testChainID, 1, "Checkup", "Follow-up", "", other, patient.PublicKey())
+	require.NoError(t, err)
+	valid, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+
+	// Am Pool vorbei eingeschleust, z.B. aus einem manipulierten Journal
+	invalid.EncryptedData.Ciphertext = []byte("tampered")
+	for _, tx := range []*blockchain.Transaction{invalid, later, valid} {
+		require.NoError(t, authorityNode.TransactionPool.AddTransactionToPool(tx))
+	}
+
+	block, err := authorityNode.CreateBlock()
+	require.NoError(t, err)
+	require.Equal(t, []*blockchain.Transaction{valid}, block.Transactions)
+	require.Zero(t, authorityNode.TransactionPool.Len(), "the invalid transaction and its successor must be evicted")
+}
+
+func TestAddTransactionHandlerStatusCodes(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	submit := func(body []byte) int {
+		recorder := httptest.NewRecorder()
+		authorityNode.AddTransactionHandler(recorder, httptest.NewRequest(http.MethodPost, "/addTransaction", bytes.NewReader(body)))
+		return recorder.Code
+	}
+
+	tx, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	body, err := json.Marshal(tx)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusBadRequest, submit([]byte(`{"hash":`)))
+	require.Equal(t, http.StatusBadRequest, submit([]byte(`{"unknownField":1}`)))
+
+	tampered := *tx
+	tampered.Patient = []byte("not a key")
+	tamperedBody, err := json.Marshal(&tampered)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, submit(tamperedBody))
+
+	require.Equal(t, http.StatusOK, submit(body))
+	require.Equal(t, http.StatusConflict, submit(body))
+}
+
+// Ein dringender Eintrag löst sofort einen Block aus, mit Rollen nur für Ärzte mit der Rolle emergency
+func TestUrgentTransactionTriggersBlock(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	doctor, emergency := newTestSigner(t), newTestSigner(t)
+	patient := newTestSigner(t)
+
+	policy := auth.NewPolicy(&auth.RoleConfig{
+		Doctors:   []string{blockchain.KeyID(utils.SerializePublicKey(doctor.PublicKey())), blockchain.KeyID(utils.SerializePublicKey(emergency.PublicKey()))},
+		Emergency: []string{blockchain.KeyID(utils.SerializePublicKey(emergency.PublicKey()))},
+	})
+	authorityNode.Auth = auth.NewAuthenticator(testChainID, policy)
+
+	routine, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, authorityNode.AddTransaction(routine))
+	delay, due := authorityNode.nextBlockDelay()
+	require.True(t, due)
+	require.Greater(t, delay, time.Minute, "a single routine transaction waits for max_block_interval")
+
+	denied, err := blockchain.NewUrgentTransaction(testChainID, 1, "Allergy", "Penicillin", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	err = authorityNode.AddTransaction(denied)
+	require.ErrorIs(t, err, ErrUrgentUnauthorized)
+	require.Equal(t, http.StatusForbidden, httpStatusFromAdmissionError(err))
+
+	// Ein eventuell noch ausstehendes Signal der ersten Transaktion verwerfen
+	select {
+	case <-authorityNode.BlockCreationTrigger:
+	default:
+	}
+
+	alert, err := blockchain.NewUrgentTransaction(testChainID, 0, "Allergy", "Penicillin", "", emergency, patient.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, authorityNode.AddTransaction(alert))
+	select {
+	case <-authorityNode.BlockCreationTrigger:
+	default:
+		t.Fatal("urgent transaction must trigger block creation")
+	}
+	delay, _ = authorityNode.nextBlockDelay()
+	require.Zero(t, delay)
+
+	block, err := authorityNode.CreateBlock()
+	require.NoError(t, err)
+	require.Equal(t, []*blockchain.Transaction{alert, routine}, block.Transactions)
+}
+
+// Eine Batch liefert ein Ergebnis je Transaktion, aufeinanderfolgende Nonces desselben Arztes werden aufgenommen
+func TestAddTransactionsHandlerReportsEachTransaction(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	authorityNode.SetupAuthorityNodeRoutes()
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	submit := func(body []byte) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		authorityNode.Mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/transactions:batch", bytes.NewReader(body)))
+		return recorder
+	}
+
+	var transactions []*blockchain.Transaction
+	for nonce := range uint64(3) {
+		tx, err := blockchain.NewTransaction(testChainID, nonce, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+		require.NoError(t, err)
+		transactions = append(transactions, tx)
+	}
+	// Die zweite Transaktion doppelt, dazu ein leerer Eintrag
+	body, err := json.Marshal(client.TransactionBatch{Transactions: []*blockchain.Transaction{transactions[0], transactions[1], transactions[1], nil, transactions[2]}})
+	require.NoError(t, err)
+
+	recorder := submit(body)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response client.BatchResponse
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	require.Equal(t, 3, response.Accepted)
+
+	var statuses []int
+	for i, result := range response.Results {
+		require.Equal(t, i, result.Index)
+		statuses = append(statuses, result.Status)
+	}
+	require.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusConflict, http.StatusBadRequest, http.StatusOK}, statuses)
+	require.Equal(t, hex.EncodeToString(transactions[2].Hash), response.Results[4].Hash)
+	require.Equal(t, 3, authorityNode.TransactionPool.Len())
+
+	require.Equal(t, http.StatusBadRequest, submit([]byte(`{"transactions":[]}`)).Code)
+	tooLarge, err := json.Marshal(client.TransactionBatch{Transactions: make([]*blockchain.Transaction, client.MaxBatchSize+1)})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, submit(tooLarge).Code)
+}
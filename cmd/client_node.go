@@ -1,43 +1,91 @@
 package cmd
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/ed25519"
-	"encoding/base64"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/webhook"
+	"google.golang.org/grpc"
 )
 
 type Node struct {
-	Blockchain           *blockchain.Blockchain
-	Doctors              map[string]DoctorData
-	Patients             map[string]PatientData
-	AuthorityNodeAddress string
-	TrustedPublicKey     *ecdsa.PublicKey
+	Blockchain            *blockchain.Blockchain
+	Doctors               map[string]DoctorData
+	AuthorityNodeAddress  string
+	Peers                 []string                // weitere Nodes als Quelle, wenn der Authority Node nicht erreichbar ist
+	TrustedAuthorities    []*ecdsa.PublicKey      // Schlüssel, deren Blöcke übernommen werden (festgelegt oder aus dem Genesis-Block)
+	Webhooks              *webhook.Manager        // Benachrichtigungen über neue Patienteneinträge
+	Auth                  *auth.Authenticator     // nil, wenn die Authentifizierung deaktiviert ist
+	RequestSigner         utils.Signer            // signiert Anfragen an den Authority Node, falls gesetzt
+	BlockPolicy           *blockchain.BlockPolicy // Blockerzeugung des Authority Nodes, nil solange unbekannt
+	GenesisHash           []byte                  // festgelegter Genesis-Hash, nil: jeder Genesis-Block wird übernommen
+	InsecureTrustFirstUse bool                    // ohne Vertrauensanker den Schlüssel des Authority Nodes ungeprüft übernehmen
+	TLSConfig             *tls.Config             // TLS für die eigene API, nil: unverschlüsselt
+	ClientTLS             *tls.Config             // TLS (ggf. mit Client-Zertifikat) für Anfragen an andere Nodes
+	Mux                   *http.ServeMux          // Routen dieses Nodes, mehrere Nodes können in einem Prozess laufen
+	HTTPAddr              string                  // Adresse der HTTP-API, nach Start die tatsächliche Adresse
+	GRPCAddr              string                  // Adresse der gRPC-API, leer: deaktiviert
+
+	stateMutex sync.RWMutex // schützt TrustedAuthorities und BlockPolicy, die die Synchronisierung setzt
+	server     *http.Server
+	grpcServer *grpc.Server
+	stopping   chan struct{} // wird bei Stop geschlossen und beendet die Event-Streams
+	stopOnce   sync.Once
+	synced     chan uint64 // liefert einmal die Höhe, mit der die Blockchain den Stand des Authority Nodes erreicht hat
+	syncedOnce sync.Once
+	ctx        context.Context
+	cancel     context.CancelFunc
+	background sync.WaitGroup
 }
 
-func NewNode(privateKey *ecdsa.PrivateKey, authorityNodeAddress string) *Node {
+func NewNode(bc *blockchain.Blockchain, authorityNodeAddress string) *Node {
 	return &Node{
-		Blockchain:           &blockchain.Blockchain{Blocks: []*blockchain.Block{}, BlockMap: make(map[string]*blockchain.Block)},
+		Blockchain:           bc,
 		Doctors:              make(map[string]DoctorData),
-		Patients:             make(map[string]PatientData),
 		AuthorityNodeAddress: authorityNodeAddress,
+		Mux:                  http.NewServeMux(),
+		stopping:             make(chan struct{}),
+		synced:               make(chan uint64, 1),
 	}
 }
 
+// markSynced meldet, dass die Blockchain den Stand des Authority Nodes erreicht hat. Erst die Blöcke
+// danach gelten für die Webhooks als neu. Der Aufrufer ist die Routine, die Blöcke übernimmt.
+func (n *Node) markSynced() {
+	n.syncedOnce.Do(func() { n.synced <- uint64(n.Blockchain.Len()) })
+}
+
 type DoctorData struct {
 	FirstName string            `json:"first_name"`
 	LastName  string            `json:"last_name"`
 	PublicKey ed25519.PublicKey `json:"public_key"`
 }
 
-type PatientData struct {
-	Transactions map[string]*blockchain.Transaction `json:"transactions"`
+// authorityClient erstellt einen Client für den Authority Node, der Anfragen mit dem Node-Schlüssel signiert
+func (n *Node) authorityClient() *client.NodeClient {
+	return n.nodeClient(n.AuthorityNodeAddress)
+}
+
+func (n *Node) nodeClient(address string) *client.NodeClient {
+	var options []client.Option
+	if n.ClientTLS != nil {
+		options = append(options, client.WithTLS(n.ClientTLS))
+	}
+	if n.RequestSigner != nil {
+		options = append(options, client.WithSigner(n.RequestSigner, n.Blockchain.ChainID))
+	}
+	return client.NewNodeClient(address, options...)
 }
 
 // Transaction to Authority-Client
@@ -47,74 +95,58 @@ func (n *Node) ForwardTransaction(transaction *blockchain.Transaction) error {
 	}
 
 	fmt.Printf("Forwarding transaction with hash %x to authority node at %s\n", transaction.Hash, n.AuthorityNodeAddress)
-	return nil
+	return n.authorityClient().AddTransaction(context.Background(), transaction)
 }
 
-func (n *Node) Listen(addr string) {
-	http.ListenAndServe(addr, nil)
-}
-
-// check if conditions are met every 5th minute with sleep
-func (n *Node) StartSyncRoutine() {
-	// Create the ticker inside the Go routine
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		// Sync Blockchain
-		n.AuthorityNodeDiscovery()
+// StartSyncRoutine abonniert die neuen Blöcke des Authority Nodes. Bricht das Abonnement ab,
+// wird per Polling synchronisiert und mit wachsendem Abstand erneut abonniert. Vor jedem Versuch
+// wird der Schlüssel des Authority Nodes geprüft, bei einer Abweichung wird die Synchronisierung angehalten.
+// Die Routine endet mit ctx.
+func (n *Node) StartSyncRoutine(ctx context.Context) {
+	backoff := minSyncBackoff
+
+	for ctx.Err() == nil {
+		err := n.VerifyAuthorityKey(ctx)
+		if err == nil {
+			var connected bool
+			connected, err = n.FollowAuthorityNode(ctx)
+			fmt.Printf("Verbindung zum Event-Stream des Authority Nodes unterbrochen: %v\n", err)
+			if connected {
+				backoff = minSyncBackoff
+			}
+
+			// Fallback: bis zum nächsten Verbindungsversuch per Polling synchronisieren
+			if !errors.Is(err, errUntrustedAuthority) && ctx.Err() == nil {
+				err = n.SyncWithAuthorityNode(ctx, n.AuthorityNodeAddress)
+			}
+		}
+
+		if errors.Is(err, errUntrustedAuthority) {
+			haltSync(err)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Printf("Fehler bei der Synchronisierung mit dem Authority Node: %v\n", err)
+			n.SyncWithPeers(ctx)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff = min(backoff*2, maxSyncBackoff)
 	}
 }
 
-// Give the ClientNOde the publicKey of AuthorityNode
-func (n *Node) AuthorityNodeDiscovery() {
-	resp, err := http.Get(fmt.Sprintf("http://%s/getPublicKey", n.AuthorityNodeAddress))
-	if err != nil {
-		fmt.Printf("Fehler beim Verbinden mit dem Authority Node: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Fehlerhafte Antwort vom Authority Node: %d\n", resp.StatusCode)
-		return
-	}
-
-	var result map[string]string
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		fmt.Printf("Fehler beim Dekodieren der Antwort: %v\n", err)
-		return
-	}
-
-	publicKeyBytes, ok := result["publicKey"]
-	if !ok {
-		fmt.Println("Public Key nicht in der Antwort gefunden")
-		return
-	}
-
-	publicKeyBytesDecoded, err := base64.StdEncoding.DecodeString(publicKeyBytes)
-	if err != nil {
-		fmt.Printf("error decoding base64 public key: %v", err)
-		return
-	}
-
-	publicKey, err := utils.DeserializePublicKey(publicKeyBytesDecoded)
-	if err != nil {
-		fmt.Printf("couldn't deserialize doctor public key: %v", err)
-		return
-	}
-
-	n.TrustedPublicKey = publicKey
-
-	fmt.Println("Authority Node Public Key erfolgreich erhalten und gespeichert")
-
-	// Sync Blockchain
-	err = n.SyncWithAuthorityNode(n.AuthorityNodeAddress)
-	if err != nil {
-		fmt.Printf("Fehler bei der Synchronisierung mit dem Authority Node: %v\n", err)
-		return
-	}
-
-	fmt.Println("Blockchain erfolgreich synchronisiert")
+// haltSync meldet eine Abweichung von der festgelegten Authority unübersehbar. Der Node bedient
+// weiterhin die bereits geprüften Blöcke, übernimmt aber keine neuen mehr.
+func haltSync(err error) {
+	fmt.Println("==================================================================")
+	fmt.Println("SYNCHRONISIERUNG ANGEHALTEN: Authority Node ist nicht vertrauenswürdig")
+	fmt.Println(err)
+	fmt.Println("Festgelegte Schlüssel (--authority_key) und Genesis-Hash prüfen, dann den Node neu starten.")
+	fmt.Println("==================================================================")
 }
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+// Der Client Node beantwortet Leseanfragen aus der synchronisierten Blockchain, wartende Transaktionen
+// des Authority Nodes kennt er nicht
+func TestClientNodeServesSyncedChain(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	authorityNode.HTTPAddr = "127.0.0.1:0"
+	authorityNode.SetupAuthorityNodeRoutes()
+	require.NoError(t, authorityNode.Start(context.Background()))
+	defer stopNodes(t, authorityNode)
+
+	doctor, patient := newTestSigner(t), newTestSigner(t)
+	included := addTestBlocks(t, authorityNode, doctor, patient, 2)
+	pending, err := blockchain.NewTransaction(testChainID, 2, "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, authorityNode.AddTransaction(pending))
+
+	clientNode := NewNode(blockchain.NewEmptyBlockchain(testChainID), authorityNode.HTTPAddr)
+	clientNode.TrustedAuthorities = []*ecdsa.PublicKey{authorityNode.Signer.PublicKey()}
+	clientNode.SetupClientNodeRoutes()
+	require.NoError(t, clientNode.SyncWithAuthorityNode(context.Background(), authorityNode.HTTPAddr))
+	require.Equal(t, 3, clientNode.Blockchain.Len())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		clientNode.Mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	// Patienten
+	recorder := get("/getPatientTransactions?patientID=" + base64.URLEncoding.EncodeToString(utils.SerializePublicKey(patient.PublicKey())))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var transactions []*blockchain.Transaction
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&transactions))
+	require.Equal(t, included, transactions)
+
+	unknownPatient := base64.URLEncoding.EncodeToString(utils.SerializePublicKey(newTestSigner(t).PublicKey()))
+	require.Equal(t, http.StatusNotFound, get("/getPatientTransactions?patientID="+unknownPatient).Code)
+	require.Equal(t, http.StatusBadRequest, get("/getPatientTransactions").Code)
+
+	// Blöcke
+	for _, ref := range []string{"2", "latest", hex.EncodeToString(authorityNode.Blockchain.LatestBlock().Hash)} {
+		recorder = get("/v1/blocks/" + ref)
+		require.Equal(t, http.StatusOK, recorder.Code, ref)
+		var block blockchain.Block
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&block))
+		require.Equal(t, uint64(2), block.ID)
+	}
+	require.Equal(t, http.StatusNotFound, get("/v1/blocks/3").Code)
+	require.Equal(t, http.StatusNotFound, get("/v1/blocks/"+hex.EncodeToString(pending.Hash)).Code)
+	require.Equal(t, http.StatusBadRequest, get("/v1/blocks/first").Code)
+
+	// Transaktionen
+	recorder = get("/tx/" + hex.EncodeToString(included[0].Hash))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var status client.TransactionStatus
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&status))
+	require.Equal(t, client.TransactionStatusIncluded, status.Status)
+	require.Equal(t, uint64(1), status.Block.ID)
+	require.Equal(t, uint64(2), status.Confirmations)
+
+	require.Equal(t, http.StatusNotFound, get("/tx/"+hex.EncodeToString(pending.Hash)).Code)
+}
+
+// Ein neuer Client Node meldet beim ersten Synchronisieren nicht die vorhandenen Blöcke, sondern erst die
+// danach erzeugten
+func TestWebhooksSkipHistoryOfFreshClientNode(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	authorityNode.HTTPAddr = "127.0.0.1:0"
+	authorityNode.SetupAuthorityNodeRoutes()
+	require.NoError(t, authorityNode.Start(context.Background()))
+
+	doctor, patient := newTestSigner(t), newTestSigner(t)
+	addTestBlocks(t, authorityNode, doctor, patient, 2)
+
+	received := make(chan webhook.Payload, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.Payload
+		if json.NewDecoder(r.Body).Decode(&payload) == nil {
+			received <- payload
+		}
+	}))
+	defer receiver.Close()
+
+	webhooks, err := webhook.NewManager(filepath.Join(t.TempDir(), "webhooks.json"))
+	require.NoError(t, err)
+	webhooks.AllowPrivateTargets = true
+	_, err = webhooks.Register(receiver.URL, blockchain.KeyID(utils.SerializePublicKey(patient.PublicKey())), "")
+	require.NoError(t, err)
+
+	clientNode := NewNode(blockchain.NewEmptyBlockchain(testChainID), authorityNode.HTTPAddr)
+	clientNode.TrustedAuthorities = []*ecdsa.PublicKey{authorityNode.Signer.PublicKey()}
+	clientNode.Webhooks = webhooks
+	clientNode.HTTPAddr = "127.0.0.1:0"
+	clientNode.SetupClientNodeRoutes()
+	require.NoError(t, clientNode.Start(context.Background()))
+	defer stopNodes(t, clientNode, authorityNode)
+
+	require.Eventually(t, func() bool { return clientNode.Blockchain.Len() == 3 }, 5*time.Second, 10*time.Millisecond)
+	included := addTestBlocks(t, authorityNode, doctor, patient, 1)
+
+	select {
+	case payload := <-received:
+		require.Equal(t, uint64(3), payload.BlockID)
+		require.Equal(t, hex.EncodeToString(included[0].Hash), payload.TxHash)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook for the new block was not delivered")
+	}
+	require.Empty(t, received)
+}
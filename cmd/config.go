@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/config"
+	"github.com/spf13/cobra"
+)
+
+// configFileEnv nennt die Konfigurationsdatei, wenn --config fehlt
+const configFileEnv = config.EnvPrefix + "_CONFIG"
+
+var configFile string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Verwaltet die Konfigurationsdatei",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Prüft die Konfigurationsdatei samt Umgebungsvariablen und referenzierten Dateien",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := resolveConfig()
+		if err != nil {
+			fmt.Println("Fehler beim Laden der Konfiguration:", err)
+			os.Exit(1)
+		}
+
+		if err := cfg.Validate(); err != nil {
+			fmt.Println("Konfiguration ist ungültig:")
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Println("  -", line)
+			}
+			os.Exit(1)
+		}
+
+		if configFile == "" {
+			fmt.Println("Keine Konfigurationsdatei angegeben, Umgebungsvariablen sind gültig")
+			return
+		}
+		fmt.Printf("Konfiguration %s ist gültig\n", configFile)
+	},
+}
+
+var configEnvCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Listet die Umgebungsvariablen, die Werte der Konfigurationsdatei überschreiben",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(configFileEnv)
+		for _, name := range config.EnvNames() {
+			fmt.Println(name)
+		}
+	},
+}
+
+// resolveConfig liest die Konfigurationsdatei (falls angegeben) und wendet die Umgebungsvariablen an
+func resolveConfig() (*config.Config, error) {
+	cfg := &config.Config{}
+	if configFile != "" {
+		var err error
+		if cfg, err = config.Load(configFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyConfig übernimmt Datei und Umgebungsvariablen in alle Flags des Befehls, die nicht explizit
+// gesetzt sind. Reihenfolge: Flag vor Umgebungsvariable vor Datei vor Standardwert.
+func applyConfig(cmd *cobra.Command) error {
+	cfg, err := resolveConfig()
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	for name, value := range configFlags(cmd, cfg) {
+		flag := flags.Lookup(name)
+		if flag == nil || flag.Changed || value == "" {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid config value for --%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// configFlags ordnet die Werte der Konfiguration den Flags zu. Der Abschnitt node gilt nur für den
+// Befehl node, client für alle Befehle, die einen Node ansprechen.
+func configFlags(cmd *cobra.Command, cfg *config.Config) map[string]string {
+	values := map[string]string{
+		"chain_id": cfg.ChainID,
+		"ca":       cfg.TLS.CA,
+		"tls_cert": cfg.TLS.Cert,
+		"tls_key":  cfg.TLS.Key,
+	}
+
+	if cmd != nodeCmd {
+		values["node_address"] = cfg.Client.NodeAddress
+		values["key"] = cfg.Client.Key
+		return values
+	}
+
+	values["listen"] = cfg.Node.Listen
+	values["grpc_listen"] = cfg.Node.GRPCListen
+	values["data_dir"] = cfg.Node.DataDir
+	values["key"] = cfg.Node.Key
+	values["authority"] = cfg.Node.Authority
+	values["peer"] = strings.Join(cfg.Node.Peers, ",")
+	values["roles"] = cfg.Node.Roles
+	values["webhook_allow_private"] = boolValue(cfg.Node.WebhookAllowPrivate)
+	values["authority_key"] = strings.Join(cfg.Trust.AuthorityKeys, ",")
+	values["genesis_hash"] = cfg.Trust.GenesisHash
+	values["insecure_trust_first_use"] = boolValue(cfg.Trust.InsecureFirstUse)
+	values["mtls"] = boolValue(cfg.TLS.MTLS)
+	values["log_file"] = cfg.Log.File
+	values["pool_max_txs"] = intValue(cfg.Pool.MaxTransactions)
+	values["pool_max_per_doctor"] = intValue(cfg.Pool.MaxPerDoctor)
+	values["pool_ttl"] = durationValue(time.Duration(cfg.Pool.TTL))
+
+	// Eine explizit angegebene Policy-Datei ersetzt die Policy aus der Konfiguration
+	if !cmd.Flags().Changed("block_policy") {
+		policy := cfg.BlockPolicy
+		values["max_block_txs"] = intValue(policy.MaxTransactions)
+		values["max_block_bytes"] = intValue(policy.MaxBlockBytes)
+		values["min_block_interval"] = durationValue(time.Duration(policy.MinInterval))
+		values["max_block_interval"] = durationValue(time.Duration(policy.MaxInterval))
+		values["empty_blocks"] = boolValue(policy.EmptyBlocks)
+	}
+	return values
+}
+
+// boolValue, intValue und durationValue lassen Nullwerte leer, damit das Flag seinen Standardwert behält
+func boolValue(value bool) string {
+	if !value {
+		return ""
+	}
+	return "true"
+}
+
+func intValue(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+func durationValue(value time.Duration) string {
+	if value == 0 {
+		return ""
+	}
+	return value.String()
+}
+
+// openLogFile hängt die Ausgabe des Nodes an die Logdatei an
+func openLogFile(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	os.Stdout = file
+	os.Stderr = file
+	return nil
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configEnvCmd)
+	rootCmd.AddCommand(configCmd)
+}
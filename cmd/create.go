@@ -1,13 +1,18 @@
 package cmd
 
 import (
-	"bytes"
+	"bufio"
+	"context"
+	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
 	"github.com/spf13/cobra"
 )
@@ -19,24 +24,73 @@ var (
 	results     string
 	pubKeyFile  string
 	privKeyFile string
+	txNonce     int64
+	urgent      bool
+	batchFile   string
+	offline     bool
+	outFile     string
 )
 
+// batchRecord ist eine Zeile der Datei für create --batch (JSON Lines)
+type batchRecord struct {
+	Type    string `json:"type"`
+	Notes   string `json:"notes"`
+	Results string `json:"results"`
+	Patient string `json:"patient"` // Pfad zum Public Key des Patienten (PEM)
+	Urgent  bool   `json:"urgent"`
+}
+
 var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Erstellt eine neue Transaktion",
-	Long:  "Dieser Befehl ermöglicht es, eine neue Transaktion lokal zu erstellen.",
+	Long: `Dieser Befehl ermöglicht es, eine neue Transaktion lokal zu erstellen.
+Mit --batch werden alle Einträge einer JSON-Lines-Datei signiert und in einem Aufruf eingereicht.
+Mit --offline --out wird die signierte Transaktion nur in eine Datei geschrieben und später mit
+tx submit eingereicht.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if batchFile == "" && (txType == "" || pubKeyFile == "") {
+			fmt.Println("--type und --patient sind erforderlich (oder --batch)")
+			os.Exit(1)
+		}
+		if offline && (outFile == "" || txNonce < 0 || batchFile != "") {
+			// Ohne Node kann die nächste freie Nonce nicht erfragt werden
+			fmt.Println("--offline erfordert --out und --nonce und ist mit --batch nicht möglich")
+			os.Exit(1)
+		}
+
 		// Lade den privaten Schlüssel des Arztes
-		senderPrivKey, _, err := utils.LoadPrivateKey(privKeyFile)
+		sender, err := utils.LoadSigner(privKeyFile)
 		if err != nil {
 			fmt.Println("Fehler beim Laden des privaten Schlüssels:", err)
 			os.Exit(1)
 		}
 
+		ctx := context.Background()
+		nodeClient := client.NewNodeClient(nodeAddress, append(clientOptions(), client.WithSigner(sender, chainID))...)
+
+		// Ohne explizite Nonce wird die nächste freie Nonce beim Node erfragt
+		nonce := uint64(txNonce)
+		if txNonce < 0 {
+			nonce, err = nodeClient.GetNonce(ctx, utils.SerializePublicKey(sender.PublicKey()))
+			if err != nil {
+				fmt.Println("Fehler beim Abrufen der Nonce:", err)
+				os.Exit(1)
+			}
+		}
+
+		if batchFile != "" {
+			createBatch(ctx, nodeClient, sender, nonce)
+			return
+		}
+
 		patientPubKey, err := utils.LoadPublicKey(pubKeyFile)
+		if err != nil {
+			fmt.Println("Fehler beim Laden des Public Keys des Patienten:", err)
+			os.Exit(1)
+		}
 
-		// Erstelle die Transaktion
-		transaction, err := blockchain.NewTransaction(txType, notes, results, senderPrivKey, patientPubKey)
+		// Erstelle die Transaktion, dringende kommen sofort in einen Block
+		transaction, err := newRecord(nonce, batchRecord{Type: txType, Notes: notes, Results: results, Urgent: urgent}, sender, patientPubKey)
 		if err != nil {
 			fmt.Println("Fehler beim Erstellen der Transaktion:", err)
 			os.Exit(1)
@@ -52,26 +106,186 @@ var createCmd = &cobra.Command{
 		fmt.Println("Transaktion erfolgreich erstellt:")
 		fmt.Println(string(txJSON))
 
-		resp, err := http.Post(fmt.Sprintf("http://%s/addTransaction", nodeAddress), "application/json", bytes.NewBuffer(txJSON))
-		if err != nil {
-			fmt.Printf("failed to send sync request: %v", err)
-      return
+		if outFile != "" {
+			if err := writeTransactionFile(outFile, transaction); err != nil {
+				fmt.Println("Fehler beim Schreiben der Transaktion:", err)
+				os.Exit(1)
+			}
+			fmt.Println("Transaktion gespeichert in", outFile)
+		}
+		if offline {
+			fmt.Println("Nicht eingereicht, später mit: tx submit", outFile)
+			return
+		}
+
+		if err := nodeClient.AddTransaction(ctx, transaction); err != nil {
+			fmt.Println("Fehler beim Senden der Transaktion:", err)
+			os.Exit(1)
 		}
-		defer resp.Body.Close()
 	},
 }
 
+// newRecord erstellt und signiert eine Transaktion für einen Eintrag
+func newRecord(nonce uint64, record batchRecord, sender utils.Signer, patientPubKey *ecdsa.PublicKey) (*blockchain.Transaction, error) {
+	if record.Urgent {
+		return blockchain.NewUrgentTransaction(chainID, nonce, record.Type, record.Notes, record.Results, sender, patientPubKey)
+	}
+	return blockchain.NewTransaction(chainID, nonce, record.Type, record.Notes, record.Results, sender, patientPubKey)
+}
+
+// createBatch signiert alle Einträge der Batch-Datei mit fortlaufenden Nonces ab nonce und reicht sie in
+// Teilen ein, die die Grenzen des Nodes einhalten. Fehlerhafte Zeilen brechen ab, bevor etwas gesendet wird.
+func createBatch(ctx context.Context, nodeClient *client.NodeClient, sender utils.Signer, nonce uint64) {
+	transactions, lines, err := readBatch(batchFile, sender, nonce)
+	if err != nil {
+		fmt.Println("Fehler beim Lesen der Batch-Datei:", err)
+		os.Exit(1)
+	}
+
+	chunks, err := splitBatch(transactions, auth.MaxRequestBodySize)
+	if err != nil {
+		fmt.Println("Fehler beim Aufteilen der Batch:", err)
+		os.Exit(1)
+	}
+
+	accepted, rejected, err := submitBatch(ctx, nodeClient.AddTransactions, chunks, lines)
+	fmt.Printf("%d Transaktionen aufgenommen, %d abgelehnt, %d nicht gesendet\n", accepted, rejected, len(transactions)-accepted-rejected)
+	if err != nil {
+		fmt.Println("Fehler beim Senden der Batch:", err)
+		os.Exit(1)
+	}
+	if accepted < len(transactions) {
+		os.Exit(1)
+	}
+}
+
+// splitBatch teilt die Transaktionen in Aufrufe mit höchstens client.MaxBatchSize Transaktionen, deren
+// kodierter Body höchstens maxBytes groß ist
+func splitBatch(transactions []*blockchain.Transaction, maxBytes int) ([][]*blockchain.Transaction, error) {
+	// Platz für {"transactions":[]}
+	const envelope = 32
+
+	var chunks [][]*blockchain.Transaction
+	var chunk []*blockchain.Transaction
+	size := envelope
+	for _, transaction := range transactions {
+		data, err := json.Marshal(transaction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize transaction: %v", err)
+		}
+		if envelope+len(data) > maxBytes {
+			return nil, fmt.Errorf("transaction %x exceeds the maximum request size of %d bytes", transaction.Hash, maxBytes)
+		}
+
+		// Ein Komma trennt die Transaktionen
+		if len(chunk) == client.MaxBatchSize || size+len(data)+1 > maxBytes {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, envelope
+		}
+		chunk = append(chunk, transaction)
+		size += len(data) + 1
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// submitBatch reicht die Teile nacheinander ein und meldet das Ergebnis je Zeile. Nach einer Ablehnung
+// werden keine weiteren Teile gesendet, ihre Nonces hätten eine Lücke und würden ebenfalls abgelehnt.
+func submitBatch(ctx context.Context, submit func(context.Context, []*blockchain.Transaction) (*client.BatchResponse, error), chunks [][]*blockchain.Transaction, lines []int) (accepted, rejected int, err error) {
+	start := 0
+	for _, chunk := range chunks {
+		response, err := submit(ctx, chunk)
+		if err != nil {
+			return accepted, rejected, err
+		}
+
+		for _, result := range response.Results {
+			if result.Index < 0 || result.Index >= len(chunk) {
+				return accepted, rejected, fmt.Errorf("invalid result index %d for a batch of %d transactions", result.Index, len(chunk))
+			}
+			line := lines[start+result.Index]
+			if result.Status == http.StatusOK {
+				fmt.Printf("Zeile %d: aufgenommen (%s)\n", line, result.Hash)
+				accepted++
+				continue
+			}
+			fmt.Printf("Zeile %d: abgelehnt (%d): %s\n", line, result.Status, result.Error)
+			rejected++
+		}
+
+		start += len(chunk)
+		if response.Accepted < len(chunk) {
+			// Spätere Einträge müssen nach einer Korrektur erneut eingereicht werden
+			break
+		}
+	}
+	return accepted, rejected, nil
+}
+
+// readBatch liest und signiert die Einträge, lines enthält die Zeilennummer jeder Transaktion
+func readBatch(path string, sender utils.Signer, nonce uint64) (transactions []*blockchain.Transaction, lines []int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	patientKeys := make(map[string]*ecdsa.PublicKey)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record batchRecord
+		decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if record.Type == "" || record.Patient == "" {
+			return nil, nil, fmt.Errorf("line %d: type and patient are required", line)
+		}
+
+		patientPubKey, loaded := patientKeys[record.Patient]
+		if !loaded {
+			if patientPubKey, err = utils.LoadPublicKey(record.Patient); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			patientKeys[record.Patient] = patientPubKey
+		}
+
+		transaction, err := newRecord(nonce+uint64(len(transactions)), record, sender, patientPubKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		transactions = append(transactions, transaction)
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(transactions) == 0 {
+		return nil, nil, fmt.Errorf("no records in %s", path)
+	}
+	return transactions, lines, nil
+}
+
 func init() {
-	createCmd.Flags().StringVarP(&nodeAddress, "node_address", "a", "", "Typ der Transaktion (erforderlich)")
+	createCmd.Flags().StringVarP(&nodeAddress, "node_address", "a", "", "Adresse des Nodes")
 	createCmd.Flags().StringVarP(&txType, "type", "t", "", "Typ der Transaktion (erforderlich)")
 	createCmd.Flags().StringVarP(&notes, "notes", "n", "", "Notizen zur Transaktion")
 	createCmd.Flags().StringVarP(&results, "results", "r", "", "Ergebnisse der Transaktion")
 	createCmd.Flags().StringVarP(&pubKeyFile, "patient", "p", "", "Public Key des Patienten in Hex (erforderlich)")
 	createCmd.Flags().StringVarP(&privKeyFile, "key", "k", "private_key.pem", "Pfad zum privaten Schlüssel des Arztes")
-
-	createCmd.MarkFlagRequired("type")
-	createCmd.MarkFlagRequired("doctor")
-	createCmd.MarkFlagRequired("patient")
+	createCmd.Flags().Int64Var(&txNonce, "nonce", -1, "Nonce der Transaktion (Standard: nächste freie Nonce vom Node)")
+	createCmd.Flags().BoolVar(&urgent, "urgent", false, "Dringender Eintrag (z.B. Allergiewarnung), wird sofort in einen Block aufgenommen (Rolle emergency)")
+	createCmd.Flags().StringVar(&batchFile, "batch", "", "JSON-Lines-Datei mit einem Eintrag pro Zeile (type, notes, results, patient, urgent), ersetzt --type und --patient")
+	createCmd.Flags().BoolVar(&offline, "offline", false, "Transaktion nur signieren und mit --out speichern, ohne Verbindung zum Node (erfordert --nonce)")
+	createCmd.Flags().StringVar(&outFile, "out", "", "Signierte Transaktion zusätzlich in diese Datei schreiben (JSON), einreichen mit tx submit")
 
 	rootCmd.AddCommand(createCmd)
 }
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitBatchRespectsCountAndSize(t *testing.T) {
+	doctor, patient := newTestSigner(t), newTestSigner(t)
+	transactions := make([]*blockchain.Transaction, client.MaxBatchSize+5)
+	for i := range transactions {
+		tx, err := blockchain.NewTransaction(testChainID, uint64(i), "Checkup", "Routine checkup", "", doctor, patient.PublicKey())
+		require.NoError(t, err)
+		transactions[i] = tx
+	}
+
+	chunks, err := splitBatch(transactions, 1<<30)
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+	require.Len(t, chunks[0], client.MaxBatchSize)
+	require.Len(t, chunks[1], 5)
+
+	// Nach Größe: jeder Body bleibt unter der Grenze, die Reihenfolge bleibt erhalten
+	data, err := json.Marshal(transactions[0])
+	require.NoError(t, err)
+	maxBytes := 3*len(data) + 100
+	chunks, err = splitBatch(transactions[:10], maxBytes)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 3)
+	var joined []*blockchain.Transaction
+	for _, chunk := range chunks {
+		body, err := json.Marshal(client.TransactionBatch{Transactions: chunk})
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(body), maxBytes)
+		joined = append(joined, chunk...)
+	}
+	require.Equal(t, transactions[:10], joined)
+
+	_, err = splitBatch(transactions[:1], len(data))
+	require.Error(t, err)
+}
+
+// Nach einer Ablehnung werden spätere Teile nicht mehr gesendet
+func TestSubmitBatchStopsAfterRejection(t *testing.T) {
+	chunks := [][]*blockchain.Transaction{{{}, {}}, {{}, {}}, {{}}}
+	lines := []int{1, 2, 4, 5, 6}
+
+	var calls int
+	submit := func(ctx context.Context, chunk []*blockchain.Transaction) (*client.BatchResponse, error) {
+		calls++
+		if calls == 2 {
+			return &client.BatchResponse{Accepted: 1, Results: []client.BatchResult{
+				{Index: 0, Status: http.StatusOK},
+				{Index: 1, Status: http.StatusConflict, Error: "nonce already used"},
+			}}, nil
+		}
+		return &client.BatchResponse{Accepted: 2, Results: []client.BatchResult{{Index: 0, Status: http.StatusOK}, {Index: 1, Status: http.StatusOK}}}, nil
+	}
+
+	accepted, rejected, err := submitBatch(context.Background(), submit, chunks, lines)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+	require.Equal(t, 3, accepted)
+	require.Equal(t, 1, rejected)
+
+	// Ein Index außerhalb des Teils führt zu einem Fehler statt einer Panik
+	invalid := func(ctx context.Context, chunk []*blockchain.Transaction) (*client.BatchResponse, error) {
+		return &client.BatchResponse{Results: []client.BatchResult{{Index: len(chunk), Status: http.StatusOK}}}, nil
+	}
+	_, _, err = submitBatch(context.Background(), invalid, chunks, lines)
+	require.ErrorContains(t, err, "invalid result index")
+}
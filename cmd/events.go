@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+)
+
+const (
+	// eventKeepaliveInterval hält Verbindungen über Proxies hinweg offen
+	eventKeepaliveInterval = 15 * time.Second
+	// poolEventBuffer ist die Anzahl Pool-Ereignisse, die ein langsamer Empfänger zurückliegen darf
+	poolEventBuffer = 64
+)
+
+// PoolEventBroker verteilt Änderungen am Transaktionspool an alle offenen Event-Streams.
+// Pool-Ereignisse sind nur informativ: ein Empfänger, der nicht nachkommt, verpasst Ereignisse.
+type PoolEventBroker struct {
+	subscribers map[chan client.PoolEvent]struct{}
+	mutex       sync.Mutex
+}
+
+func NewPoolEventBroker() *PoolEventBroker {
+	return &PoolEventBroker{subscribers: make(map[chan client.PoolEvent]struct{})}
+}
+
+// Subscribe liefert einen Kanal mit allen folgenden Pool-Ereignissen und eine Funktion zum Abmelden
+func (b *PoolEventBroker) Subscribe() (<-chan client.PoolEvent, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	events := make(chan client.PoolEvent, poolEventBuffer)
+	b.subscribers[events] = struct{}{}
+
+	return events, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.subscribers, events)
+	}
+}
+
+func (b *PoolEventBroker) Publish(event client.PoolEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// EventsHandler streamt neue Blöcke als Server-Sent Events: /v1/events?from=<id>
+func (node *Node) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	node.streamEvents(w, r, nil)
+}
+
+// EventsHandler streamt zusätzlich die Änderungen am Transaktionspool
+func (a *AuthorityNode) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	poolEvents, unsubscribe := a.PoolEvents.Subscribe()
+	defer unsubscribe()
+
+	a.Node.streamEvents(w, r, poolEvents)
+}
+
+func (node *Node) streamEvents(w http.ResponseWriter, r *http.Request, poolEvents <-chan client.PoolEvent) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	next, err := parseUintParam(r, "from", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Beim automatischen Reconnect eines EventSource setzt der Browser die ID des letzten Blocks
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		if id, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+			next = id + 1
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeEvent(w, client.EventReady, "", node.chainInfo()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		// Den Kanal vor dem Lesen holen, sonst könnte ein Block zwischen Lesen und Warten verloren gehen
+		changed := node.Blockchain.Changed()
+
+		blocks := node.Blockchain.BlockRange(next, streamBatchSize)
+		for _, block := range blocks {
+			if err := writeEvent(w, client.EventBlock, strconv.FormatUint(block.ID, 10), block); err != nil {
+				return
+			}
+			next = block.ID + 1
+		}
+		if len(blocks) > 0 {
+			flusher.Flush()
+			continue
+		}
+
+		select {
+		case <-changed:
+		case event := <-poolEvents:
+			if err := writeEvent(w, client.EventPool, "", event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-node.stopping:
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, eventType, id string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+	return err
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/api/nodepb"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// streamBatchSize begrenzt, wie viele Blöcke StreamBlocks pro Durchlauf aus der Kette liest
+const streamBatchSize = 100
+
+// NodeGRPCServer stellt die gRPC-API eines Client Nodes bereit
+type NodeGRPCServer struct {
+	nodepb.UnimplementedNodeServiceServer
+	node *Node
+}
+
+func NewNodeGRPCServer(node *Node) *NodeGRPCServer {
+	return &NodeGRPCServer{node: node}
+}
+
+// AuthorityGRPCServer ergänzt den NodeGRPCServer um die Funktionen des Authority Nodes
+type AuthorityGRPCServer struct {
+	*NodeGRPCServer
+	authority *AuthorityNode
+}
+
+func NewAuthorityGRPCServer(authorityNode *AuthorityNode) *AuthorityGRPCServer {
+	return &AuthorityGRPCServer{
+		NodeGRPCServer: NewNodeGRPCServer(authorityNode.Node),
+		authority:      authorityNode,
+	}
+}
+
+// SubmitTransaction leitet die Transaktion an den Authority Node weiter
+func (s *NodeGRPCServer) SubmitTransaction(ctx context.Context, req *nodepb.SubmitTransactionRequest) (*nodepb.SubmitTransactionResponse, error) {
+	if req.GetTransaction() == nil {
+		return nil, status.Error(codes.InvalidArgument, "transaction is required")
+	}
+
+	transaction := nodepb.ToTransaction(req.GetTransaction())
+	if err := s.node.ForwardTransaction(transaction); err != nil {
+		return nil, grpcStatusFromClientError(err)
+	}
+
+	return &nodepb.SubmitTransactionResponse{Hash: transaction.Hash}, nil
+}
+
+func (s *NodeGRPCServer) GetBlock(ctx context.Context, req *nodepb.GetBlockRequest) (*nodepb.GetBlockResponse, error) {
+	if err := s.authorize(ctx, readerRoles); err != nil {
+		return nil, err
+	}
+
+	var block *blockchain.Block
+	var exists bool
+	switch ref := req.GetRef().(type) {
+	case *nodepb.GetBlockRequest_Id:
+		block, exists = s.node.Blockchain.GetBlockByID(ref.Id)
+	case *nodepb.GetBlockRequest_Hash:
+		block, exists = s.node.Blockchain.GetBlockByHash(hex.EncodeToString(ref.Hash))
+	default:
+		block = s.node.Blockchain.LatestBlock()
+		exists = block != nil
+	}
+
+	if !exists {
+		return nil, status.Error(codes.NotFound, "block not found")
+	}
+
+	return &nodepb.GetBlockResponse{Block: nodepb.FromBlock(block)}, nil
+}
+
+// StreamBlocks sendet zuerst alle vorhandenen Blöcke ab from_id und wartet dann auf neue Blöcke
+func (s *NodeGRPCServer) StreamBlocks(req *nodepb.StreamBlocksRequest, stream nodepb.NodeService_StreamBlocksServer) error {
+	if err := s.authorize(stream.Context(), readerRoles); err != nil {
+		return err
+	}
+
+	next := req.GetFromId()
+	for {
+		// Den Kanal vor dem Lesen holen, sonst könnte ein Block zwischen Lesen und Warten verloren gehen
+		changed := s.node.Blockchain.Changed()
+
+		blocks := s.node.Blockchain.BlockRange(next, streamBatchSize)
+		for _, block := range blocks {
+			if err := stream.Send(&nodepb.StreamBlocksResponse{Block: nodepb.FromBlock(block)}); err != nil {
+				return err
+			}
+			next = block.ID + 1
+		}
+		if len(blocks) > 0 {
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-s.node.stopping:
+			return status.Error(codes.Unavailable, "node is shutting down")
+		}
+	}
+}
+
+func (s *NodeGRPCServer) GetPatientTransactions(ctx context.Context, req *nodepb.GetPatientTransactionsRequest) (*nodepb.GetPatientTransactionsResponse, error) {
+	if len(req.GetPatient()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "patient is required")
+	}
+
+	patientID := blockchain.KeyID(req.GetPatient())
+	if err := s.authorize(ctx, []auth.Role{auth.RoleAuditor}, patientID); err != nil {
+		return nil, err
+	}
+
+	transactions := s.node.Blockchain.PatientTransactions(patientID)
+	return &nodepb.GetPatientTransactionsResponse{Transactions: nodepb.FromTransactions(transactions)}, nil
+}
+
+// GetPublicKey liefert den Public Key des Authority Nodes, sobald der Client Node ihn abgerufen hat
+func (s *NodeGRPCServer) GetPublicKey(ctx context.Context, req *nodepb.GetPublicKeyRequest) (*nodepb.GetPublicKeyResponse, error) {
+	trusted := s.node.trustedAuthorities()
+	if len(trusted) == 0 {
+		return nil, status.Error(codes.Unavailable, "authority public key not known yet")
+	}
+	return &nodepb.GetPublicKeyResponse{PublicKey: utils.SerializePublicKey(trusted[0])}, nil
+}
+
+// SubmitTransaction nimmt die Transaktion direkt in den Pool des Authority Nodes auf
+func (s *AuthorityGRPCServer) SubmitTransaction(ctx context.Context, req *nodepb.SubmitTransactionRequest) (*nodepb.SubmitTransactionResponse, error) {
+	if req.GetTransaction() == nil {
+		return nil, status.Error(codes.InvalidArgument, "transaction is required")
+	}
+
+	transaction := nodepb.ToTransaction(req.GetTransaction())
+	if err := s.authority.AddTransaction(transaction); err != nil {
+		return nil, grpcStatusFromAdmissionError(err)
+	}
+
+	return &nodepb.SubmitTransactionResponse{Hash: transaction.Hash}, nil
+}
+
+func (s *AuthorityGRPCServer) GetPublicKey(ctx context.Context, req *nodepb.GetPublicKeyRequest) (*nodepb.GetPublicKeyResponse, error) {
+	return &nodepb.GetPublicKeyResponse{PublicKey: utils.SerializePublicKey(s.authority.Signer.PublicKey())}, nil
+}
+
+// authorize prüft die vom Interceptor ermittelte Identität gegen die Rollen bzw. Eigentümer
+func (s *NodeGRPCServer) authorize(ctx context.Context, roles []auth.Role, owners ...string) error {
+	if s.node.Auth == nil {
+		return nil
+	}
+
+	identity := auth.IdentityFromContext(ctx)
+	if identity == nil {
+		return status.Error(codes.Unauthenticated, auth.ErrUnauthenticated.Error())
+	}
+	if !s.node.Auth.Allowed(identity, roles, owners...) {
+		return status.Error(codes.PermissionDenied, auth.ErrForbidden.Error())
+	}
+	return nil
+}
+
+// NewGRPCServer erstellt den gRPC-Server für den Service. Mit Authenticator werden Session-Tokens aus den Metadaten ("authorization: Bearer <token>") bzw.
+// Client-Zertifikate geprüft, mit tlsConfig ist die Verbindung per TLS geschützt.
+func NewGRPCServer(service nodepb.NodeServiceServer, authenticator *auth.Authenticator, tlsConfig *tls.Config) *grpc.Server {
+	var options []grpc.ServerOption
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if authenticator != nil {
+		options = append(options,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				ctx, err := authenticateGRPC(ctx, authenticator)
+				if err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				ctx, err := authenticateGRPC(stream.Context(), authenticator)
+				if err != nil {
+					return err
+				}
+				return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+			}),
+		)
+	}
+
+	server := grpc.NewServer(options...)
+	nodepb.RegisterNodeServiceServer(server, service)
+	return server
+}
+
+// authenticateGRPC legt die Identität aus dem Bearer-Token bzw. dem Client-Zertifikat in den Context.
+// Ohne beides bleibt sie leer.
+func authenticateGRPC(ctx context.Context, authenticator *auth.Authenticator) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		if p, ok := peer.FromContext(ctx); ok {
+			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+				if identity := auth.IdentityFromTLS(&tlsInfo.State); identity != nil {
+					return auth.ContextWithIdentity(ctx, identity), nil
+				}
+			}
+		}
+		return ctx, nil
+	}
+
+	token, found := strings.CutPrefix(values[0], "Bearer ")
+	if !found {
+		return nil, status.Error(codes.Unauthenticated, "only bearer tokens are supported via gRPC")
+	}
+	identity, err := authenticator.AuthenticateToken(strings.TrimSpace(token))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return auth.ContextWithIdentity(ctx, identity), nil
+}
+
+// authenticatedStream ersetzt den Context eines Streams durch den mit der Identität
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcStatusFromAdmissionError übersetzt die Ablehnungsgründe von AddTransaction in gRPC-Statuscodes
+func grpcStatusFromAdmissionError(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidTransaction):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrDoctorUnauthorized), errors.Is(err, ErrUrgentUnauthorized):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrNonceUsed), errors.Is(err, blockchain.ErrPoolDuplicate):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrNonceGap):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, blockchain.ErrPoolFull), errors.Is(err, blockchain.ErrPoolDoctorLimit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		// Kein Fehler der Transaktion, z.B. beim Schreiben des Pools
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// grpcStatusFromClientError übersetzt Fehler beim Weiterleiten an den Authority Node in gRPC-Statuscodes
+func grpcStatusFromClientError(err error) error {
+	var apiErr *client.APIError
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, client.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, client.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.As(err, &apiErr):
+		switch apiErr.StatusCode {
+		case http.StatusForbidden:
+			return status.Error(codes.PermissionDenied, apiErr.Message)
+		case http.StatusTooManyRequests:
+			return status.Error(codes.ResourceExhausted, apiErr.Message)
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+			return status.Error(codes.InvalidArgument, apiErr.Message)
+		default:
+			return status.Error(codes.Internal, apiErr.Message)
+		}
+	default:
+		// Authority Node nicht erreichbar
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
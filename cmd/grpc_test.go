@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/api/nodepb"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPCClient startet den Service im Speicher (bufconn) und liefert einen Client dafür
+func newTestGRPCClient(t *testing.T, service nodepb.NodeServiceServer, authenticator *auth.Authenticator) nodepb.NodeServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(service, authenticator, nil)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return nodepb.NewNodeServiceClient(conn)
+}
+
+// withToken meldet den Schlüssel per Challenge an und legt das Session-Token in die Metadaten
+func withToken(t *testing.T, authenticator *auth.Authenticator, signer utils.Signer) context.Context {
+	keyID := blockchain.KeyID(utils.SerializePublicKey(signer.PublicKey()))
+	challenge, _, err := authenticator.IssueChallenge(keyID)
+	require.NoError(t, err)
+	signature, err := auth.SignChallenge(signer, testChainID, keyID, challenge)
+	require.NoError(t, err)
+	token, _, err := authenticator.RedeemChallenge(keyID, challenge, signature)
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestGRPCSubmitTransactionStatusCodes(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	authorityNode.TransactionPool.Limits.MaxPerDoctor = 1
+	doctor, stranger := newTestSigner(t), newTestSigner(t)
+	patient := newTestSigner(t)
+	authorityNode.Auth = auth.NewAuthenticator(testChainID, auth.NewPolicy(&auth.RoleConfig{
+		Doctors: []string{blockchain.KeyID(utils.SerializePublicKey(doctor.PublicKey()))},
+	}))
+	nodeClient := newTestGRPCClient(t, NewAuthorityGRPCServer(authorityNode), authorityNode.Auth)
+
+	submit := func(tx *blockchain.Transaction) error {
+		request := &nodepb.SubmitTransactionRequest{}
+		if tx != nil {
+			request.Transaction = nodepb.FromTransaction(tx)
+		}
+		_, err := nodeClient.SubmitTransaction(context.Background(), request)
+		return err
+	}
+	newTx := func(chainID string, nonce uint64, signer utils.Signer) *blockchain.Transaction {
+		tx, err := blockchain.NewTransaction(chainID, nonce, "Checkup", "Routine checkup", "", signer, patient.PublicKey())
+		require.NoError(t, err)
+		return tx
+	}
+
+	valid := newTx(testChainID, 0, doctor)
+	require.NoError(t, submit(valid))
+
+	for name, test := range map[string]struct {
+		tx   *blockchain.Transaction
+		code codes.Code
+	}{
+		"missing":     {nil, codes.InvalidArgument},
+		"wrong chain": {newTx("other-chain", 0, doctor), codes.InvalidArgument},
+		"no doctor":   {newTx(testChainID, 0, stranger), codes.PermissionDenied},
+		"duplicate":   {valid, codes.AlreadyExists},
+		"nonce gap":   {newTx(testChainID, 2, doctor), codes.FailedPrecondition},
+		"pool limit":  {newTx(testChainID, 1, doctor), codes.ResourceExhausted},
+	} {
+		require.Equal(t, test.code, status.Code(submit(test.tx)), name)
+	}
+	require.Equal(t, 1, authorityNode.TransactionPool.Len())
+}
+
+// StreamBlocks liefert die vorhandenen Blöcke ab from_id und danach jeden neuen Block
+func TestGRPCStreamBlocksResumesAndFollows(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	doctor, patient := newTestSigner(t), newTestSigner(t)
+	addTestBlocks(t, authorityNode, doctor, patient, 2)
+	nodeClient := newTestGRPCClient(t, NewAuthorityGRPCServer(authorityNode), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := nodeClient.StreamBlocks(ctx, &nodepb.StreamBlocksRequest{FromId: 1})
+	require.NoError(t, err)
+
+	for _, id := range []uint64{1, 2} {
+		response, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, id, response.GetBlock().GetId())
+	}
+
+	addTestBlocks(t, authorityNode, doctor, patient, 1)
+	response, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), response.GetBlock().GetId())
+	require.Equal(t, authorityNode.Blockchain.LatestBlock().Hash, response.GetBlock().GetHash())
+}
+
+// Der Interceptor lehnt fehlende oder ungültige Tokens ab, die Methoden prüfen die Rolle
+func TestGRPCAuthInterceptor(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	auditor, doctor := newTestSigner(t), newTestSigner(t)
+	authorityNode.Auth = auth.NewAuthenticator(testChainID, auth.NewPolicy(&auth.RoleConfig{
+		Auditors: []string{blockchain.KeyID(utils.SerializePublicKey(auditor.PublicKey()))},
+		Doctors:  []string{blockchain.KeyID(utils.SerializePublicKey(doctor.PublicKey()))},
+	}))
+	nodeClient := newTestGRPCClient(t, NewAuthorityGRPCServer(authorityNode), authorityNode.Auth)
+
+	getBlock := func(ctx context.Context) codes.Code {
+		_, err := nodeClient.GetBlock(ctx, &nodepb.GetBlockRequest{})
+		return status.Code(err)
+	}
+	streamBlocks := func(ctx context.Context) codes.Code {
+		stream, err := nodeClient.StreamBlocks(ctx, &nodepb.StreamBlocksRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		return status.Code(err)
+	}
+
+	for name, ctx := range map[string]context.Context{
+		"without token": context.Background(),
+		"unknown token": metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer unknown"),
+		"no bearer":     metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic dXNlcg=="),
+	} {
+		require.Equal(t, codes.Unauthenticated, getBlock(ctx), name)
+		require.Equal(t, codes.Unauthenticated, streamBlocks(ctx), name)
+	}
+
+	doctorCtx := withToken(t, authorityNode.Auth, doctor)
+	require.Equal(t, codes.PermissionDenied, getBlock(doctorCtx))
+	require.Equal(t, codes.PermissionDenied, streamBlocks(doctorCtx))
+
+	auditorCtx := withToken(t, authorityNode.Auth, auditor)
+	require.Equal(t, codes.OK, getBlock(auditorCtx))
+	require.Equal(t, codes.OK, streamBlocks(auditorCtx))
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keyInFile  string
+	keyOutFile string
+)
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Verwaltet Schlüssel",
+}
+
+var keyEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Verschlüsselt einen PEM-Schlüssel in einen passwortgeschützten Keystore",
+	Long:  "Die Passphrase wird aus der Umgebungsvariable " + utils.KeystorePassphraseEnv + " gelesen.",
+	Run: func(cmd *cobra.Command, args []string) {
+		privKey, _, err := utils.LoadPrivateKey(keyInFile)
+		if err != nil {
+			fmt.Println("Fehler beim Laden des privaten Schlüssels:", err)
+			os.Exit(1)
+		}
+
+		keystore, err := utils.EncryptKeystore(privKey, os.Getenv(utils.KeystorePassphraseEnv))
+		if err != nil {
+			fmt.Println("Fehler beim Verschlüsseln des Schlüssels:", err)
+			os.Exit(1)
+		}
+
+		if err := os.WriteFile(keyOutFile, keystore, 0600); err != nil {
+			fmt.Println("Fehler beim Schreiben des Keystores:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Keystore gespeichert unter %s\n", keyOutFile)
+	},
+}
+
+var keyIDCmd = &cobra.Command{
+	Use:   "id",
+	Short: "Gibt die KeyID eines Public Keys aus, wie sie in der Rollendatei verwendet wird",
+	Run: func(cmd *cobra.Command, args []string) {
+		publicKey, err := utils.LoadPublicKey(keyInFile)
+		if err != nil {
+			fmt.Println("Fehler beim Laden des Public Keys:", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(blockchain.KeyID(utils.SerializePublicKey(publicKey)))
+	},
+}
+
+func init() {
+	keyEncryptCmd.Flags().StringVarP(&keyInFile, "in", "i", "", "Pfad zum PEM-Schlüssel (erforderlich)")
+	keyEncryptCmd.Flags().StringVarP(&keyOutFile, "out", "o", "", "Pfad für den Keystore (erforderlich)")
+	keyEncryptCmd.MarkFlagRequired("in")
+	keyEncryptCmd.MarkFlagRequired("out")
+
+	keyIDCmd.Flags().StringVarP(&keyInFile, "in", "i", "", "Pfad zum Public Key (erforderlich)")
+	keyIDCmd.MarkFlagRequired("in")
+
+	keyCmd.AddCommand(keyEncryptCmd, keyIDCmd)
+	rootCmd.AddCommand(keyCmd)
+}
@@ -1,46 +1,326 @@
 package cmd
 
 import (
+	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/webhook"
 	"github.com/spf13/cobra"
 )
 
 var (
-	authorityAddress string
-	port             string
+	authorityAddress  string
+	port              string
+	listenAddress     string
+	dataDir           string
+	grpcPort          string
+	grpcListenAddress string
+	nodeKeyFile       string
+	peerAddresses     []string
+	logFile           string
+	rolesFile         string
+	requireMTLS       bool
+	authorityKeyFiles []string
+	genesisHash       string
+	insecureFirstUse  bool
+	webhookPrivate    bool
+
+	blockPolicyFile  string
+	maxBlockTxs      int
+	maxBlockBytes    int
+	minBlockInterval time.Duration
+	maxBlockInterval time.Duration
+	emptyBlocks      bool
+
+	poolMaxTxs       int
+	poolMaxPerDoctor int
+	poolTTL          time.Duration
 )
 
-// TODO: Port hinzufügen per Parameter -p --port
 var nodeCmd = &cobra.Command{
 	Use:   "node",
 	Short: "Start a node",
 	Long:  `Start a node either as an authority node or as a client node.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if logFile != "" {
+			if err := openLogFile(logFile); err != nil {
+				fmt.Println("Fehler beim Öffnen der Logdatei:", err)
+				os.Exit(1)
+			}
+		}
+
+		// Gespeicherte Blöcke werden beim Laden gegen die festgelegten Schlüssel geprüft
+		authorityKeys := loadAuthorityKeys()
+		bc, err := openBlockchain(chainID, dataDir, authorityKeys)
+		if err != nil {
+			fmt.Println("Fehler beim Laden der Blockchain:", err)
+			os.Exit(1)
+		}
+
 		if authorityAddress == "" {
-			authorityNodePrivateKey, authorityNodePublicKey, err := utils.LoadPrivateKey(privKeyFile)
+			if len(peerAddresses) > 0 {
+				fmt.Println("--peer ist nur für Client Nodes (--authority) möglich")
+				os.Exit(1)
+			}
+			authoritySigner, err := utils.LoadSigner(nodeKeyFile)
 			if err != nil {
 				fmt.Println("Fehler beim Laden des privaten Schlüssels:", err)
 				os.Exit(1)
 			}
-			authorityNode := NewAuthorityNode(authorityNodePrivateKey, authorityNodePublicKey)
+			authorityNode, err := NewAuthorityNode(bc, authoritySigner, loadBlockPolicy(cmd))
+			if err != nil {
+				fmt.Println("Fehler beim Starten des Authority Nodes:", err)
+				os.Exit(1)
+			}
+			authorityNode.TransactionPool.Limits = loadPoolLimits()
 			fmt.Println("Starting Authority Node...")
+			configureNode(authorityNode.Node, authorityKeys)
+			authorityNode.Auth = loadAuthenticator()
+			if authorityNode.Auth != nil {
+				// Der Schlüssel der Authority ist immer Operator
+				authorityNode.Auth.Policy.Grant(auth.RoleOperator, blockchain.KeyID(utils.SerializePublicKey(authoritySigner.PublicKey())))
+			}
+			if dataDir != "" {
+				// Angenommene, noch nicht aufgenommene Transaktionen überstehen so einen Absturz. Sie werden
+				// erst nach dem Laden der Rollen erneut geprüft.
+				journal, err := blockchain.OpenPoolJournal(filepath.Join(dataDir, "pool.journal"))
+				if err == nil {
+					err = authorityNode.RestorePool(journal)
+				}
+				if err != nil {
+					fmt.Println("Fehler beim Laden des Transaktionspools:", err)
+					os.Exit(1)
+				}
+			}
+			enableWebhooks(authorityNode.Node)
 			authorityNode.SetupAuthorityNodeRoutes()
-			authorityNode.Listen(":" + port)
+			setListenAddresses(authorityNode.Node)
+			runService(authorityNode)
 		} else {
-			node := NewNode(nil, authorityAddress)
+			node := NewNode(bc, authorityAddress)
 			fmt.Printf("Starting Client Node... Connecting to Authority Node at %s\n", authorityAddress)
-			node.SetupNodeRoutes()
-			go node.StartSyncRoutine()
-			node.Listen(":" + port)
+			configureNode(node, authorityKeys)
+			// Blöcke von Peers werden nur gegen festgelegte Schlüssel geprüft, nie gegen einen übernommenen
+			if len(peerAddresses) > 0 && len(node.TrustedAuthorities) == 0 {
+				fmt.Println("--peer erfordert --authority_key")
+				os.Exit(1)
+			}
+			// Ohne Vertrauensanker würde jeder Schlüssel übernommen, den der Authority Node vorlegt
+			node.InsecureTrustFirstUse = insecureFirstUse
+			if !node.hasTrustAnchor() && !node.InsecureTrustFirstUse {
+				fmt.Println("Client Node ohne Vertrauensanker: --authority_key oder --genesis_hash angeben (nur zum Testen: --insecure_trust_first_use)")
+				os.Exit(1)
+			}
+			node.Peers = peerAddresses
+			node.Auth = loadAuthenticator()
+			// Mit eigenem Schlüssel signiert der Client Node seine Anfragen an den Authority Node (Rolle auditor)
+			if cmd.Flags().Changed("key") {
+				if node.RequestSigner, err = utils.LoadSigner(nodeKeyFile); err != nil {
+					fmt.Println("Fehler beim Laden des privaten Schlüssels:", err)
+					os.Exit(1)
+				}
+			}
+			enableWebhooks(node)
+			node.SetupClientNodeRoutes()
+			setListenAddresses(node)
+			runService(node)
 		}
 	},
 }
 
+// setListenAddresses setzt die Adressen der APIs: --listen bzw. --grpc_listen oder alle Interfaces mit
+// --port bzw. --grpc_port. gRPC bleibt ohne beides deaktiviert.
+func setListenAddresses(node *Node) {
+	node.HTTPAddr = listenAddress
+	if node.HTTPAddr == "" {
+		node.HTTPAddr = ":" + port
+	}
+
+	node.GRPCAddr = grpcListenAddress
+	if node.GRPCAddr == "" && grpcPort != "" {
+		node.GRPCAddr = ":" + grpcPort
+	}
+	if node.GRPCAddr != "" {
+		fmt.Printf("Starting gRPC server on %s\n", node.GRPCAddr)
+	}
+}
+
+// configureNode setzt TLS sowie die festgelegten Vertrauensanker (Public Key der Authority, Genesis-Hash)
+func configureNode(node *Node, authorityKeys []*ecdsa.PublicKey) {
+	var err error
+	if tlsCertFile != "" || tlsKeyFile != "" {
+		if node.TLSConfig, err = utils.ServerTLSConfig(tlsFiles(), requireMTLS); err != nil {
+			fmt.Println("Fehler beim Laden der TLS-Konfiguration:", err)
+			os.Exit(1)
+		}
+	} else if requireMTLS {
+		fmt.Println("--mtls erfordert --tls_cert und --tls_key")
+		os.Exit(1)
+	}
+	// Dasselbe Zertifikat dient gegenüber anderen Nodes als Client-Zertifikat (mTLS)
+	if tlsFiles().Enabled() {
+		if node.ClientTLS, err = utils.ClientTLSConfig(tlsFiles()); err != nil {
+			fmt.Println("Fehler beim Laden der TLS-Konfiguration:", err)
+			os.Exit(1)
+		}
+	}
+
+	// Festgelegte Schlüssel werden nur noch mit /getPublicKey abgeglichen, nie ersetzt
+	node.TrustedAuthorities = authorityKeys
+
+	if genesisHash != "" {
+		if node.GenesisHash, err = hex.DecodeString(genesisHash); err != nil {
+			fmt.Println("Ungültiger Genesis-Hash:", err)
+			os.Exit(1)
+		}
+		if genesis, exists := node.Blockchain.GetBlockByID(0); exists {
+			if err := node.checkGenesis(genesis); err != nil {
+				fmt.Println("Gespeicherte Blockchain passt nicht zum festgelegten Genesis-Hash:", err)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// loadBlockPolicy lädt die Policy für die Blockerzeugung aus --block_policy, einzelne Flags überschreiben die Datei
+func loadBlockPolicy(cmd *cobra.Command) blockchain.BlockPolicy {
+	policy := blockchain.DefaultBlockPolicy()
+	if blockPolicyFile != "" {
+		var err error
+		if policy, err = blockchain.LoadBlockPolicy(blockPolicyFile); err != nil {
+			fmt.Println("Fehler beim Laden der Block-Policy:", err)
+			os.Exit(1)
+		}
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("max_block_txs") {
+		policy.MaxTransactions = maxBlockTxs
+	}
+	if flags.Changed("max_block_bytes") {
+		policy.MaxBlockBytes = maxBlockBytes
+	}
+	if flags.Changed("min_block_interval") {
+		policy.MinInterval = blockchain.Duration(minBlockInterval)
+	}
+	if flags.Changed("max_block_interval") {
+		policy.MaxInterval = blockchain.Duration(maxBlockInterval)
+	}
+	if flags.Changed("empty_blocks") {
+		policy.EmptyBlocks = emptyBlocks
+	}
+	return policy
+}
+
+// loadPoolLimits liefert die Grenzen des Transaktionspools aus den Flags
+func loadPoolLimits() blockchain.PoolLimits {
+	limits := blockchain.PoolLimits{
+		MaxTransactions: poolMaxTxs,
+		MaxPerDoctor:    poolMaxPerDoctor,
+		TTL:             blockchain.Duration(poolTTL),
+	}
+	if err := limits.Validate(); err != nil {
+		fmt.Println("Fehler in den Grenzen des Transaktionspools:", err)
+		os.Exit(1)
+	}
+	return limits
+}
+
+// loadAuthenticator aktiviert die Authentifizierung, sofern eine Rollendatei angegeben ist
+func loadAuthenticator() *auth.Authenticator {
+	if rolesFile == "" {
+		return nil
+	}
+
+	config, err := auth.LoadRoleConfig(rolesFile)
+	if err != nil {
+		fmt.Println("Fehler beim Laden der Rollen:", err)
+		os.Exit(1)
+	}
+	return auth.NewAuthenticator(chainID, auth.NewPolicy(config))
+}
+
+// enableWebhooks lädt die Webhook-Registrierungen (persistent, falls ein Datenverzeichnis angegeben ist).
+// Mit Datenverzeichnis wird nach einem Neustart auch für die zwischenzeitlich erzeugten Blöcke benachrichtigt.
+func enableWebhooks(node *Node) {
+	path := ""
+	if dataDir != "" {
+		path = filepath.Join(dataDir, "webhooks.json")
+	}
+
+	webhooks, err := webhook.NewManager(path)
+	if err != nil {
+		fmt.Println("Fehler beim Laden der Webhooks:", err)
+		os.Exit(1)
+	}
+	webhooks.AllowPrivateTargets = webhookPrivate
+
+	node.Webhooks = webhooks
+}
+
+// loadAuthorityKeys lädt die mit --authority_key festgelegten Public Keys der Authority
+func loadAuthorityKeys() []*ecdsa.PublicKey {
+	var authorityKeys []*ecdsa.PublicKey
+	for _, keyFile := range authorityKeyFiles {
+		publicKey, err := utils.LoadPublicKey(keyFile)
+		if err != nil {
+			fmt.Println("Fehler beim Laden des Public Keys der Authority:", err)
+			os.Exit(1)
+		}
+		authorityKeys = append(authorityKeys, publicKey)
+	}
+	return authorityKeys
+}
+
+// openBlockchain lädt die Blockchain aus dem Datenverzeichnis oder erstellt ohne Verzeichnis eine leere In-Memory-Blockchain
+func openBlockchain(chainID, dataDir string, authorityKeys []*ecdsa.PublicKey) (*blockchain.Blockchain, error) {
+	if dataDir == "" {
+		return blockchain.NewEmptyBlockchain(chainID), nil
+	}
+
+	store, err := blockchain.OpenBlockStore(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return blockchain.LoadBlockchain(chainID, store, authorityKeys)
+}
+
 func init() {
 	nodeCmd.Flags().StringVarP(&authorityAddress, "authority", "a", "", "IP address of the authority node")
 	nodeCmd.Flags().StringVarP(&port, "port", "p", "8080", "Port für den Node")
+	nodeCmd.Flags().StringVar(&listenAddress, "listen", "", "Adresse der HTTP-API, z.B. 127.0.0.1:8080 (überschreibt --port)")
+	nodeCmd.Flags().StringVarP(&dataDir, "data_dir", "d", "", "Verzeichnis für Blöcke und Indizes (leer: nur im Speicher)")
+	nodeCmd.Flags().StringVar(&grpcPort, "grpc_port", "", "Port für die gRPC-API (leer: deaktiviert)")
+	nodeCmd.Flags().StringVar(&grpcListenAddress, "grpc_listen", "", "Adresse der gRPC-API (überschreibt --grpc_port)")
+	nodeCmd.Flags().StringVarP(&nodeKeyFile, "key", "k", "private_key.pem", "Schlüssel der Authority bzw. des Client Nodes für signierte Anfragen")
+	nodeCmd.Flags().StringSliceVar(&peerAddresses, "peer", nil, "Weitere Nodes, von denen ein Client Node Blöcke übernimmt, wenn der Authority Node nicht erreichbar ist")
+	nodeCmd.Flags().StringVar(&logFile, "log_file", "", "Ausgabe an diese Datei anhängen (leer: Konsole)")
+	nodeCmd.Flags().BoolVar(&requireMTLS, "mtls", false, "Client-Zertifikate verlangen, die von --ca ausgestellt sind")
+	nodeCmd.Flags().StringSliceVar(&authorityKeyFiles, "authority_key", nil, "Public Key(s) der vertrauenswürdigen Authority (PEM), mehrfach angebbar")
+	nodeCmd.Flags().StringVar(&genesisHash, "genesis_hash", "", "Erwarteter Hash des Genesis-Blocks (hex)")
+	nodeCmd.Flags().BoolVar(&insecureFirstUse, "insecure_trust_first_use", false, "Ohne --authority_key und --genesis_hash den Schlüssel des Authority Nodes ungeprüft übernehmen (nur zum Testen)")
+	nodeCmd.Flags().BoolVar(&webhookPrivate, "webhook_allow_private", false, "Webhooks an interne Adressen (Loopback, private Netze, Link-Local) erlauben")
+	nodeCmd.Flags().StringVar(&rolesFile, "roles", "", "JSON-Datei mit der Rollenzuordnung, aktiviert die Authentifizierung (leer: deaktiviert)")
+
+	defaults := blockchain.DefaultBlockPolicy()
+	nodeCmd.Flags().StringVar(&blockPolicyFile, "block_policy", "", "JSON-Datei mit der Policy für die Blockerzeugung (nur Authority Node)")
+	nodeCmd.Flags().IntVar(&maxBlockTxs, "max_block_txs", defaults.MaxTransactions, "Maximale Anzahl Transaktionen pro Block, ein voller Block wird sofort erzeugt")
+	nodeCmd.Flags().IntVar(&maxBlockBytes, "max_block_bytes", defaults.MaxBlockBytes, "Maximale Größe der Transaktionen eines Blocks in Bytes")
+	nodeCmd.Flags().DurationVar(&minBlockInterval, "min_block_interval", time.Duration(defaults.MinInterval), "Mindestabstand zwischen zwei Blöcken")
+	nodeCmd.Flags().DurationVar(&maxBlockInterval, "max_block_interval", time.Duration(defaults.MaxInterval), "Spätester Abstand, nach dem wartende Transaktionen in einen Block kommen")
+	nodeCmd.Flags().BoolVar(&emptyBlocks, "empty_blocks", defaults.EmptyBlocks, "Nach max_block_interval auch leere Blöcke erzeugen (Heartbeat)")
+
+	poolDefaults := blockchain.DefaultPoolLimits()
+	nodeCmd.Flags().IntVar(&poolMaxTxs, "pool_max_txs", poolDefaults.MaxTransactions, "Maximale Anzahl wartender Transaktionen im Pool (nur Authority Node)")
+	nodeCmd.Flags().IntVar(&poolMaxPerDoctor, "pool_max_per_doctor", poolDefaults.MaxPerDoctor, "Maximale Anzahl wartender Transaktionen pro Arzt")
+	nodeCmd.Flags().DurationVar(&poolTTL, "pool_ttl", time.Duration(poolDefaults.TTL), "Nicht in einen Block aufgenommene Transaktionen nach dieser Dauer verwerfen (0: nie)")
 	rootCmd.AddCommand(nodeCmd)
 }
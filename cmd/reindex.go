@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reindexNodeAddress string
+	reindexDataDir     string
+	reindexKeyFile     string
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Baut Transaktions-, Patienten- und Arztindex aus den Blöcken neu auf",
+	Long: `Baut die abgeleiteten Indizes neu auf, entweder auf einem laufenden Node (--node_address)
+oder direkt im Datenverzeichnis eines gestoppten Nodes (--data_dir).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if reindexDataDir != "" {
+			reindexOffline(reindexDataDir)
+			return
+		}
+
+		result, err := newNodeClient(reindexNodeAddress, reindexKeyFile).Reindex(context.Background())
+		if err != nil {
+			fmt.Println("Fehler beim Neuaufbau der Indizes:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Indizes neu aufgebaut: %d Blöcke, %d Transaktionen, %d Patienten, %d Ärzte\n",
+			result.Blocks, result.Transactions, result.Patients, result.Doctors)
+	},
+}
+
+func reindexOffline(dir string) {
+	store, err := blockchain.OpenBlockStore(dir)
+	if err != nil {
+		fmt.Println("Fehler beim Öffnen des Datenverzeichnisses:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	// Offline ist der Schlüssel der Authority unbekannt, geprüft werden Hashes, Verkettung und Transaktionen
+	bc, err := blockchain.LoadBlockchain(chainID, store, nil)
+	if err != nil {
+		fmt.Println("Fehler beim Laden der Blockchain:", err)
+		os.Exit(1)
+	}
+
+	if err := bc.Reindex(); err != nil {
+		fmt.Println("Fehler beim Neuaufbau der Indizes:", err)
+		os.Exit(1)
+	}
+
+	stats := bc.Stats()
+	fmt.Printf("Indizes neu aufgebaut: %d Blöcke, %d Transaktionen\n", stats.Blocks, stats.Transactions)
+}
+
+func init() {
+	reindexCmd.Flags().StringVarP(&reindexNodeAddress, "node_address", "a", "localhost:8080", "Adresse des Nodes")
+	reindexCmd.Flags().StringVarP(&reindexDataDir, "data_dir", "d", "", "Datenverzeichnis eines gestoppten Nodes")
+	reindexCmd.Flags().StringVarP(&reindexKeyFile, "key", "k", "", "Privater Schlüssel eines Operators zum Signieren der Anfrage")
+	reindexCmd.MarkFlagsMutuallyExclusive("node_address", "data_dir")
+	rootCmd.AddCommand(reindexCmd)
+}
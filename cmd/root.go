@@ -4,13 +4,27 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
 	"github.com/spf13/cobra"
 )
 
+var (
+	chainID     string
+	caFile      string
+	tlsCertFile string
+	tlsKeyFile  string
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "go-blockchain-bachelor",
 	Short: "EGA Blockchain Application",
 	Long:  `EGA Blockchain is a distributed application to manage patient records using blockchain technology.`,
+	// Konfigurationsdatei und Umgebungsvariablen gelten für jeden Befehl
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return applyConfig(cmd)
+	},
 }
 
 func Execute() {
@@ -19,3 +33,44 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+func tlsFiles() utils.TLSFiles {
+	return utils.TLSFiles{CertFile: tlsCertFile, KeyFile: tlsKeyFile, CAFile: caFile}
+}
+
+// clientOptions liefert die Client-Optionen aus den globalen Flags, mit --ca bzw. Client-Zertifikat per HTTPS
+func clientOptions() []client.Option {
+	if !tlsFiles().Enabled() {
+		return nil
+	}
+
+	config, err := utils.ClientTLSConfig(tlsFiles())
+	if err != nil {
+		fmt.Println("Fehler beim Laden der TLS-Konfiguration:", err)
+		os.Exit(1)
+	}
+	return []client.Option{client.WithTLS(config)}
+}
+
+// newNodeClient erstellt einen Client für den Node. Mit Schlüssel werden die Anfragen signiert,
+// was Nodes mit aktivierter Authentifizierung verlangen.
+func newNodeClient(address, keyFile string) *client.NodeClient {
+	if keyFile == "" {
+		return client.NewNodeClient(address, clientOptions()...)
+	}
+
+	signer, err := utils.LoadSigner(keyFile)
+	if err != nil {
+		fmt.Println("Fehler beim Laden des privaten Schlüssels:", err)
+		os.Exit(1)
+	}
+	return client.NewNodeClient(address, append(clientOptions(), client.WithSigner(signer, chainID))...)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", os.Getenv(configFileEnv), "Konfigurationsdatei (YAML), Standard: $"+configFileEnv)
+	rootCmd.PersistentFlags().StringVar(&chainID, "chain_id", blockchain.DefaultChainID, "Chain-ID, die in alle Signaturen einfließt")
+	rootCmd.PersistentFlags().StringVar(&caFile, "ca", "", "CA-Zertifikat (PEM), dem bei TLS-Verbindungen zu Nodes vertraut wird")
+	rootCmd.PersistentFlags().StringVar(&tlsCertFile, "tls_cert", "", "Eigenes TLS-Zertifikat (PEM): Server-Zertifikat des Nodes bzw. Client-Zertifikat für mTLS")
+	rootCmd.PersistentFlags().StringVar(&tlsKeyFile, "tls_key", "", "Privater Schlüssel zum TLS-Zertifikat (PEM)")
+}
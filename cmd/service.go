@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/api/nodepb"
+)
+
+// shutdownTimeout begrenzt das geordnete Herunterfahren nach SIGINT/SIGTERM
+const shutdownTimeout = 30 * time.Second
+
+// Service ist ein Node mit Lebenszyklus. Start kehrt zurück, sobald die APIs lauschen und die
+// Hintergrundroutinen laufen. Stop fährt geordnet herunter und bricht ab, wenn ctx abläuft.
+type Service interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Start startet HTTP- und gRPC-API, die Webhooks und die Synchronisierung mit dem Authority Node
+func (n *Node) Start(ctx context.Context) error {
+	if err := n.serve(ctx, NewNodeGRPCServer(n)); err != nil {
+		return err
+	}
+	n.runBackground(n.StartSyncRoutine)
+	return nil
+}
+
+// Stop nimmt keine Anfragen mehr an, wartet auf laufende Anfragen und die Synchronisierung und schließt den Block-Store.
+// Der Block-Store wird auch geschlossen, wenn das Herunterfahren nicht rechtzeitig gelingt. Weitere Aufrufe tun nichts.
+func (n *Node) Stop(ctx context.Context) error {
+	var err error
+	n.stopOnce.Do(func() {
+		err = errors.Join(n.shutdown(ctx), n.Blockchain.Close())
+	})
+	return err
+}
+
+// Start startet den Node und die Blockerzeugung
+func (a *AuthorityNode) Start(ctx context.Context) error {
+	if err := a.serve(ctx, NewAuthorityGRPCServer(a)); err != nil {
+		return err
+	}
+	a.runBackground(a.StartBlockGenerator)
+	return nil
+}
+
+// Stop wartet wie Node.Stop auch auf einen laufenden CreateBlock und nimmt danach die wartenden
+// Transaktionen in letzte Blöcke auf, damit sie beim Neustart nicht verloren sind. Das geschieht auch,
+// wenn das Herunterfahren der APIs nicht rechtzeitig gelingt.
+func (a *AuthorityNode) Stop(ctx context.Context) error {
+	var err error
+	a.stopOnce.Do(func() {
+		err = errors.Join(a.shutdown(ctx), a.flushPool(), a.TransactionPool.Close(), a.Blockchain.Close())
+	})
+	return err
+}
+
+// flushPool erzeugt Blöcke, bis der Pool leer ist. Verwirft CreateBlock die letzten wartenden Transaktionen
+// als abgelaufen oder ungültig, bleibt nichts aufzunehmen.
+func (a *AuthorityNode) flushPool() error {
+	for a.TransactionPool.Len() > 0 {
+		block, err := a.CreateBlock()
+		if errors.Is(err, ErrPoolEmpty) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to include pending transactions in a block: %v", err)
+		}
+		fmt.Printf("Block %d mit %d wartenden Transaktionen vor dem Beenden erzeugt\n", block.ID, len(block.Transactions))
+	}
+	return nil
+}
+
+// serve startet die APIs auf HTTPAddr bzw. GRPCAddr (leer: ohne gRPC). Nach dem Start enthalten die
+// Felder die tatsächlichen Adressen, mit Port 0 lassen sich so mehrere Nodes in einem Prozess testen.
+func (n *Node) serve(ctx context.Context, service nodepb.NodeServiceServer) error {
+	var config net.ListenConfig
+	listener, err := config.Listen(ctx, "tcp", n.HTTPAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", n.HTTPAddr, err)
+	}
+	n.HTTPAddr = listener.Addr().String()
+
+	if n.GRPCAddr != "" {
+		grpcListener, err := config.Listen(ctx, "tcp", n.GRPCAddr)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on %s: %v", n.GRPCAddr, err)
+		}
+		n.GRPCAddr = grpcListener.Addr().String()
+
+		n.grpcServer = NewGRPCServer(service, n.Auth, n.TLSConfig)
+		go func() {
+			if err := n.grpcServer.Serve(grpcListener); err != nil {
+				fmt.Println("Fehler im gRPC-Server:", err)
+			}
+		}()
+	}
+
+	n.server = &http.Server{Handler: n.Mux, TLSConfig: n.TLSConfig}
+	go func() {
+		var err error
+		if n.TLSConfig != nil {
+			// Zertifikat und Schlüssel stehen bereits in der TLSConfig
+			err = n.server.ServeTLS(listener, "", "")
+		} else {
+			err = n.server.Serve(listener)
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println("Fehler im HTTP-Server:", err)
+		}
+	}()
+
+	// Die Hintergrundroutinen laufen bis Stop, unabhängig vom Context des Starts
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	if n.Webhooks != nil {
+		n.runBackground(func(ctx context.Context) {
+			n.Webhooks.Watch(ctx, n.Blockchain, n.synced)
+		})
+	}
+	return nil
+}
+
+// runBackground startet eine Hintergrundroutine, die mit dem Context des Nodes endet
+func (n *Node) runBackground(routine func(ctx context.Context)) {
+	n.background.Add(1)
+	go func() {
+		defer n.background.Done()
+		routine(n.ctx)
+	}()
+}
+
+// shutdown beendet zuerst die Event-Streams und APIs (laufende Anfragen werden abgeschlossen),
+// dann die Hintergrundroutinen. Ohne vorheriges Start ist nichts zu beenden.
+func (n *Node) shutdown(ctx context.Context) error {
+	close(n.stopping)
+	if n.server == nil {
+		return nil
+	}
+
+	var errs []error
+	if err := n.server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to shut down HTTP server: %v", err))
+	}
+	if n.grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			n.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			n.grpcServer.Stop()
+			errs = append(errs, fmt.Errorf("failed to shut down gRPC server: %v", ctx.Err()))
+		}
+	}
+
+	n.cancel()
+	done := make(chan struct{})
+	go func() {
+		n.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background routines did not stop: %v", ctx.Err()))
+	}
+	return errors.Join(errs...)
+}
+
+// runService startet den Node und fährt ihn bei SIGINT/SIGTERM geordnet herunter. Ein zweites
+// Signal beendet den Prozess sofort.
+func runService(service Service) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := service.Start(ctx); err != nil {
+		fmt.Println("Fehler beim Starten des Nodes:", err)
+		os.Exit(1)
+	}
+
+	<-ctx.Done()
+	stop()
+	fmt.Println("Node wird beendet...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := service.Stop(shutdownCtx); err != nil {
+		fmt.Println("Fehler beim Beenden des Nodes:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Node beendet")
+}
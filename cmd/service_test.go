@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/stretchr/testify/require"
+)
+
+const testChainID = "ega-test"
+
+func newTestSigner(t *testing.T) utils.Signer {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return utils.NewMemorySigner(privateKey)
+}
+
+// stopNodes beendet die Nodes nacheinander. Vorher werden ungenutzte Keep-Alive-Verbindungen der
+// Clients im Testprozess geschlossen, sonst wartet der Shutdown der Server auf sie.
+func stopNodes(t *testing.T, services ...Service) {
+	http.DefaultClient.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, service := range services {
+		require.NoError(t, service.Stop(ctx))
+		http.DefaultClient.CloseIdleConnections()
+	}
+}
+
+// Authority und Client Node laufen mit eigenen Routen im selben Prozess
+func TestNodesStartAndStopInOneProcess(t *testing.T) {
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	authorityNode, err := NewAuthorityNode(blockchain.NewEmptyBlockchain(testChainID), newTestSigner(t), blockchain.DefaultBlockPolicy())
+	require.NoError(t, err)
+	authorityNode.HTTPAddr = "127.0.0.1:0"
+	authorityNode.SetupAuthorityNodeRoutes()
+	require.NoError(t, authorityNode.Start(context.Background()))
+
+	clientNode := NewNode(blockchain.NewEmptyBlockchain(testChainID), authorityNode.HTTPAddr)
+	clientNode.TrustedAuthorities = []*ecdsa.PublicKey{authorityNode.Signer.PublicKey()}
+	clientNode.HTTPAddr = "127.0.0.1:0"
+	clientNode.SetupClientNodeRoutes()
+	require.NoError(t, clientNode.Start(context.Background()))
+
+	ctx := context.Background()
+	authorityClient := client.NewNodeClient(authorityNode.HTTPAddr)
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx, err := blockchain.NewTransaction(testChainID, nonce, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+		require.NoError(t, err)
+		require.NoError(t, authorityClient.AddTransaction(ctx, tx))
+		if nonce == 0 {
+			_, err = authorityClient.CreateBlock(ctx)
+			require.NoError(t, err)
+		}
+	}
+
+	// Der Client Node übernimmt den Block über den Event-Stream
+	require.Eventually(t, func() bool {
+		info, err := client.NewNodeClient(clientNode.HTTPAddr).GetChainInfo(ctx)
+		return err == nil && info.Height == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Beim Beenden wird die wartende Transaktion noch in einen Block aufgenommen
+	stopNodes(t, clientNode, authorityNode)
+	require.Len(t, authorityNode.Blockchain.Blocks, 3)
+	require.Zero(t, authorityNode.TransactionPool.Len())
+
+	_, err = authorityClient.GetChainInfo(ctx)
+	require.Error(t, err)
+}
+
+// Mit -race ausführen: Abfragen an beide Nodes laufen parallel zur Blockerzeugung und Synchronisierung
+func TestQueriesDuringSync(t *testing.T) {
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	authorityNode, err := NewAuthorityNode(blockchain.NewEmptyBlockchain(testChainID), newTestSigner(t), blockchain.DefaultBlockPolicy())
+	require.NoError(t, err)
+	authorityNode.HTTPAddr = "127.0.0.1:0"
+	authorityNode.SetupAuthorityNodeRoutes()
+	require.NoError(t, authorityNode.Start(context.Background()))
+
+	clientNode := NewNode(blockchain.NewEmptyBlockchain(testChainID), authorityNode.HTTPAddr)
+	clientNode.TrustedAuthorities = []*ecdsa.PublicKey{authorityNode.Signer.PublicKey()}
+	clientNode.HTTPAddr = "127.0.0.1:0"
+	clientNode.SetupClientNodeRoutes()
+	require.NoError(t, clientNode.Start(context.Background()))
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	patientKey := patient.PublicKey()
+	for _, address := range []string{authorityNode.HTTPAddr, clientNode.HTTPAddr} {
+		wg.Add(1)
+		go func(nodeClient *client.NodeClient) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// Fehler sind erlaubt, solange der Client Node noch keinen Genesis-Block hat
+				nodeClient.GetChainInfo(ctx)
+				nodeClient.ListBlocks(ctx, 0, 10)
+				nodeClient.GetPatientTransactions(ctx, utils.SerializePublicKey(patientKey))
+				nodeClient.GetNonce(ctx, utils.SerializePublicKey(doctor.PublicKey()))
+			}
+		}(client.NewNodeClient(address))
+	}
+
+	const blocks = 10
+	authorityClient := client.NewNodeClient(authorityNode.HTTPAddr)
+	for nonce := uint64(0); nonce < blocks; nonce++ {
+		tx, err := blockchain.NewTransaction(testChainID, nonce, "Checkup", "Routine checkup", "All normal", doctor, patientKey)
+		require.NoError(t, err)
+		require.NoError(t, authorityClient.AddTransaction(ctx, tx))
+		_, err = authorityClient.CreateBlock(ctx)
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		transactions, err := client.NewNodeClient(clientNode.HTTPAddr).GetPatientTransactions(ctx, utils.SerializePublicKey(patientKey))
+		return err == nil && len(transactions) == blocks
+	}, 5*time.Second, 50*time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	stopNodes(t, clientNode, authorityNode)
+}
+
+// Stop ist ohne Start und mehrfach möglich. Auch wenn das Herunterfahren nicht rechtzeitig gelingt,
+// landen die wartenden Transaktionen noch in einem Block.
+func TestStopFlushesPoolWhenShutdownTimesOut(t *testing.T) {
+	require.NoError(t, NewNode(blockchain.NewEmptyBlockchain(testChainID), "").Stop(context.Background()))
+
+	authorityNode := newTestAuthorityNode(t)
+	authorityNode.HTTPAddr = "127.0.0.1:0"
+	authorityNode.SetupAuthorityNodeRoutes()
+	require.NoError(t, authorityNode.Start(context.Background()))
+
+	doctor := newTestSigner(t)
+	tx, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Routine checkup", "All normal", doctor, newTestSigner(t).PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, authorityNode.AddTransaction(tx))
+
+	// Ein offener Event-Stream hält den HTTP-Server bis zum Ablauf des Contexts fest
+	streamCtx, closeStream := context.WithCancel(context.Background())
+	defer closeStream()
+	subscribed := make(chan struct{})
+	go client.NewNodeClient(authorityNode.HTTPAddr).SubscribeEvents(streamCtx, 0, func(event client.Event) error {
+		if event.Type == client.EventReady {
+			close(subscribed)
+		}
+		return nil
+	})
+	<-subscribed
+
+	stopCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, authorityNode.Stop(stopCtx))
+	require.Zero(t, authorityNode.TransactionPool.Len())
+	require.Equal(t, 2, authorityNode.Blockchain.Len())
+
+	require.NoError(t, authorityNode.Stop(context.Background()))
+}
+
+// Verwirft die Blockerstellung beim Beenden alle wartenden Transaktionen, ist das kein Fehler
+func TestStopSucceedsWhenPoolOnlyHoldsEvictedTransactions(t *testing.T) {
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	for name, evict := range map[string]func(*AuthorityNode){
+		"expired": func(a *AuthorityNode) { a.TransactionPool.Limits.TTL = blockchain.Duration(time.Nanosecond) },
+		// Dem Arzt wurde die Rolle nach der Aufnahme entzogen
+		"invalid": func(a *AuthorityNode) {
+			a.Auth = auth.NewAuthenticator(testChainID, auth.NewPolicy(&auth.RoleConfig{}))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			authorityNode := newTestAuthorityNode(t)
+			tx, err := blockchain.NewTransaction(testChainID, 0, "Checkup", "Routine checkup", "All normal", doctor, patient.PublicKey())
+			require.NoError(t, err)
+			require.NoError(t, authorityNode.AddTransaction(tx))
+
+			evict(authorityNode)
+			time.Sleep(time.Millisecond)
+			require.NoError(t, authorityNode.Stop(context.Background()))
+			require.Zero(t, authorityNode.TransactionPool.Len())
+			require.Equal(t, 1, authorityNode.Blockchain.Len())
+		})
+	}
+}
@@ -1,55 +1,126 @@
 package cmd
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
 )
 
-type SyncRequest struct {
-	LastBlockHash string `json:"lastBlockHash"`
-}
-
-type SyncResponse struct {
-	Blocks []*blockchain.Block `json:"blocks"`
-}
-
-func (n *Node) SyncWithAuthorityNode(authorityNodeAddress string) error {
+func (n *Node) SyncWithAuthorityNode(ctx context.Context, authorityNodeAddress string) error {
 
 	var lastBlockHash string
-	if len(n.Blockchain.Blocks) > 0 {
-		lastBlock := n.Blockchain.Blocks[len(n.Blockchain.Blocks)-1]
+	if lastBlock := n.Blockchain.LatestBlock(); lastBlock != nil {
 		lastBlockHash = fmt.Sprintf("%x", lastBlock.Hash)
 	} else {
 		fmt.Println("Blockchain is empty")
 		lastBlockHash = ""
 	}
 
-	syncRequest := SyncRequest{LastBlockHash: lastBlockHash}
-	requestBody, err := json.Marshal(syncRequest)
+	blocks, err := n.nodeClient(authorityNodeAddress).Sync(ctx, lastBlockHash)
 	if err != nil {
-		return fmt.Errorf("failed to serialize sync request: %v", err)
+		return fmt.Errorf("failed to sync with authority node: %v", err)
 	}
 
-	resp, err := http.Post(fmt.Sprintf("http://%s/sync", authorityNodeAddress), "application/json", bytes.NewBuffer(requestBody))
-	if err != nil {
-		return fmt.Errorf("failed to send sync request: %v", err)
+	for _, block := range blocks {
+		if err := n.acceptBlock(block); err != nil {
+			return err
+		}
 	}
-	defer resp.Body.Close()
+	n.markSynced()
 
-	var syncResponse SyncResponse
-	if err := json.NewDecoder(resp.Body).Decode(&syncResponse); err != nil {
-		return fmt.Errorf("failed to decode sync response: %v", err)
-	}
+	return nil
+}
 
-	n.Blockchain.Blocks = append(n.Blockchain.Blocks, syncResponse.Blocks...)
+// SyncWithPeers übernimmt fehlende Blöcke von den Peers, solange der Authority Node nicht erreichbar ist.
+// Jeder Block wird gegen die festgelegten Authority-Schlüssel geprüft, die Peers selbst müssen daher
+// nicht vertrauenswürdig sein. Ein abweichender Peer wird nur gemeldet, die Synchronisierung läuft weiter.
+func (n *Node) SyncWithPeers(ctx context.Context) {
+	for _, peer := range n.Peers {
+		if err := n.syncWithPeer(ctx, peer); err != nil {
+			fmt.Printf("Fehler bei der Synchronisierung mit Peer %s: %v\n", peer, err)
+			continue
+		}
+		return
+	}
+}
 
-	for _, block := range syncResponse.Blocks {
-		n.Blockchain.BlockMap[fmt.Sprintf("%x", block.Hash)] = block
+func (n *Node) syncWithPeer(ctx context.Context, address string) error {
+	peer := n.nodeClient(address)
+	for {
+		next := n.Blockchain.Len()
+		block, err := peer.GetBlock(ctx, strconv.Itoa(next))
+		if errors.Is(err, client.ErrNotFound) {
+			n.markSynced()
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch block %d: %v", next, err)
+		}
+		if err := n.acceptBlock(block); err != nil {
+			return err
+		}
+		fmt.Printf("Block %d von Peer %s übernommen\n", block.ID, address)
 	}
+}
 
-	return nil
+const (
+	minSyncBackoff = 1 * time.Second
+	maxSyncBackoff = 30 * time.Second
+)
+
+// FollowAuthorityNode übernimmt die Blöcke aus dem Event-Stream des Authority Nodes, bis die Verbindung
+// abbricht. connected gibt an, ob der Stream zustande kam, damit der Aufrufer seinen Backoff zurücksetzen kann.
+func (n *Node) FollowAuthorityNode(ctx context.Context) (connected bool, err error) {
+	from := uint64(n.Blockchain.Len())
+	var height uint64 // Höhe des Authority Nodes beim Abonnieren
+
+	err = n.authorityClient().SubscribeEvents(ctx, from, func(event client.Event) error {
+		// Mit dem Block, der beim Abonnieren der letzte war, ist der Stand des Authority Nodes erreicht
+		defer func() {
+			if connected && uint64(n.Blockchain.Len()) > height {
+				n.markSynced()
+			}
+		}()
+
+		switch event.Type {
+		case client.EventReady:
+			info, err := event.ChainInfo()
+			if err != nil {
+				return err
+			}
+			if info.ChainID != n.Blockchain.ChainID {
+				return fmt.Errorf("authority node serves chain %q, expected %q", info.ChainID, n.Blockchain.ChainID)
+			}
+			if n.GenesisHash != nil && info.GenesisHash != "" && info.GenesisHash != hex.EncodeToString(n.GenesisHash) {
+				return fmt.Errorf("%w: authority node serves genesis %s, expected pinned genesis %x", errUntrustedAuthority, info.GenesisHash, n.GenesisHash)
+			}
+			if info.BlockPolicy != nil {
+				n.setBlockPolicy(info.BlockPolicy)
+			}
+			height = info.Height
+			connected = true
+			fmt.Println("Event-Stream des Authority Nodes abonniert")
+		case client.EventBlock:
+			block, err := event.Block()
+			if err != nil {
+				return err
+			}
+			// Bereits vorhandene Blöcke (z.B. nach einem Polling-Durchlauf) überspringen
+			if block.ID < uint64(n.Blockchain.Len()) {
+				return nil
+			}
+			if err := n.acceptBlock(block); err != nil {
+				return err
+			}
+			fmt.Printf("Block %d vom Authority Node übernommen\n", block.ID)
+		}
+		return nil
+	})
+
+	return connected, err
 }
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+)
+
+// errUntrustedAuthority kennzeichnet Fehler, bei denen der Authority Node nicht zur festgelegten
+// Authority-Menge passt. Die Synchronisierung wird dann angehalten statt wiederholt.
+var errUntrustedAuthority = errors.New("untrusted authority")
+
+// isTrustedAuthority prüft, ob der Schlüssel zur vertrauenswürdigen Authority-Menge gehört
+func (n *Node) isTrustedAuthority(publicKey *ecdsa.PublicKey) bool {
+	for _, trusted := range n.trustedAuthorities() {
+		if trusted.Equal(publicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyAuthorityKey gleicht den Public Key des Authority Nodes mit der festgelegten Authority-Menge ab.
+// Ohne festgelegte Schlüssel wird der Schlüssel einmalig übernommen, sofern er den (festgelegten bzw.
+// gespeicherten) Genesis-Block signiert hat. Danach wird er nie mehr durch /getPublicKey ersetzt.
+// Ohne jeden Vertrauensanker wird nur mit InsecureTrustFirstUse synchronisiert.
+func (n *Node) VerifyAuthorityKey(ctx context.Context) error {
+	publicKey, err := n.authorityClient().GetPublicKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch authority public key: %v", err)
+	}
+
+	if len(n.trustedAuthorities()) > 0 {
+		if !n.isTrustedAuthority(publicKey) {
+			return fmt.Errorf("%w: authority node presents public key %s which is not pinned",
+				errUntrustedAuthority, blockchain.KeyID(utils.SerializePublicKey(publicKey)))
+		}
+		return nil
+	}
+
+	genesis, err := n.genesisBlock(ctx)
+	if err != nil {
+		return err
+	}
+	if genesis == nil {
+		if !n.InsecureTrustFirstUse {
+			return fmt.Errorf("%w: no pinned authority key, genesis hash or stored chain to verify the authority node's key", errUntrustedAuthority)
+		}
+		fmt.Println("Warnung: Public Key der Authority ist nicht festgelegt und wird ungeprüft übernommen, mit --authority_key oder --genesis_hash festlegen")
+	} else if err := genesis.ValidateBlock(publicKey, n.Blockchain.ChainID); err != nil {
+		return fmt.Errorf("%w: genesis block is not signed by the authority node's key: %v", errUntrustedAuthority, err)
+	} else if err := n.Blockchain.Verify([]*ecdsa.PublicKey{publicKey}); err != nil {
+		// Ohne festgelegten Schlüssel wurden die gespeicherten Blöcke beim Laden nicht auf ihre Signatur geprüft
+		return fmt.Errorf("%w: stored chain is not signed by the authority node's key: %v", errUntrustedAuthority, err)
+	}
+
+	n.setTrustedAuthorities([]*ecdsa.PublicKey{publicKey})
+	fmt.Println("Public Key der Authority übernommen:", blockchain.KeyID(utils.SerializePublicKey(publicKey)))
+	return nil
+}
+
+// hasTrustAnchor meldet, ob sich der Schlüssel des Authority Nodes prüfen lässt: festgelegte Schlüssel,
+// festgelegter Genesis-Hash oder gespeicherter Genesis-Block
+func (n *Node) hasTrustAnchor() bool {
+	return len(n.trustedAuthorities()) > 0 || n.GenesisHash != nil || n.Blockchain.Len() > 0
+}
+
+// genesisBlock liefert den Genesis-Block, an dem ein noch nicht festgelegter Authority-Schlüssel geprüft
+// wird: den gespeicherten oder, bei festgelegtem Genesis-Hash, den des Authority Nodes. nil ohne beides.
+func (n *Node) genesisBlock(ctx context.Context) (*blockchain.Block, error) {
+	if genesis, exists := n.Blockchain.GetBlockByID(0); exists {
+		return genesis, nil
+	}
+	if n.GenesisHash == nil {
+		return nil, nil
+	}
+
+	genesis, err := n.authorityClient().GetBlock(ctx, "0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch genesis block: %v", err)
+	}
+	if err := n.checkGenesis(genesis); err != nil {
+		return nil, err
+	}
+	return genesis, nil
+}
+
+// verifyBlock prüft Hash und Signatur eines synchronisierten Blocks gegen die Authority-Menge
+func (n *Node) verifyBlock(block *blockchain.Block) error {
+	if err := n.checkGenesis(block); err != nil {
+		return err
+	}
+	trusted := n.trustedAuthorities()
+	if len(trusted) == 0 {
+		return fmt.Errorf("%w: no trusted authority key to verify block %d", errUntrustedAuthority, block.ID)
+	}
+
+	var err error
+	for _, publicKey := range trusted {
+		if err = block.ValidateBlock(publicKey, n.Blockchain.ChainID); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: block %d is not signed by a trusted authority: %v", errUntrustedAuthority, block.ID, err)
+}
+
+// acceptBlock übernimmt einen geprüften Block vom Authority Node
+func (n *Node) acceptBlock(block *blockchain.Block) error {
+	if err := n.verifyBlock(block); err != nil {
+		return err
+	}
+	if err := n.Blockchain.AddBlock(block); err != nil {
+		return fmt.Errorf("failed to add block %d: %v", block.ID, err)
+	}
+	return nil
+}
+
+// checkGenesis prüft den Genesis-Block gegen den festgelegten Genesis-Hash, sofern einer konfiguriert ist
+func (n *Node) checkGenesis(block *blockchain.Block) error {
+	if n.GenesisHash == nil || block.ID != 0 {
+		return nil
+	}
+	if !bytes.Equal(block.Hash, n.GenesisHash) {
+		return fmt.Errorf("%w: genesis block %x does not match pinned genesis hash %x", errUntrustedAuthority, block.Hash, n.GenesisHash)
+	}
+	return nil
+}
+
+// trustedAuthorities liefert eine Kopie der Authority-Menge, die die Synchronisierung ändern kann
+func (n *Node) trustedAuthorities() []*ecdsa.PublicKey {
+	n.stateMutex.RLock()
+	defer n.stateMutex.RUnlock()
+	return append([]*ecdsa.PublicKey(nil), n.TrustedAuthorities...)
+}
+
+func (n *Node) setTrustedAuthorities(keys []*ecdsa.PublicKey) {
+	n.stateMutex.Lock()
+	defer n.stateMutex.Unlock()
+	n.TrustedAuthorities = keys
+}
+
+// blockPolicy liefert die Block-Policy, die ein Client Node vom Authority Node übernimmt
+func (n *Node) blockPolicy() *blockchain.BlockPolicy {
+	n.stateMutex.RLock()
+	defer n.stateMutex.RUnlock()
+	return n.BlockPolicy
+}
+
+func (n *Node) setBlockPolicy(policy *blockchain.BlockPolicy) {
+	n.stateMutex.Lock()
+	defer n.stateMutex.Unlock()
+	n.BlockPolicy = policy
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/stretchr/testify/require"
+)
+
+// Ohne Vertrauensanker wird der Schlüssel des Authority Nodes nur mit InsecureTrustFirstUse übernommen
+func TestVerifyAuthorityKeyRequiresTrustAnchor(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	authorityNode.HTTPAddr = "127.0.0.1:0"
+	authorityNode.SetupAuthorityNodeRoutes()
+	require.NoError(t, authorityNode.Start(context.Background()))
+
+	ctx := context.Background()
+	clientNode := NewNode(blockchain.NewEmptyBlockchain(testChainID), authorityNode.HTTPAddr)
+	require.False(t, clientNode.hasTrustAnchor())
+	require.ErrorIs(t, clientNode.VerifyAuthorityKey(ctx), errUntrustedAuthority)
+	require.Empty(t, clientNode.trustedAuthorities())
+
+	clientNode.InsecureTrustFirstUse = true
+	require.NoError(t, clientNode.VerifyAuthorityKey(ctx))
+	require.True(t, clientNode.isTrustedAuthority(authorityNode.Signer.PublicKey()))
+
+	stopNodes(t, authorityNode)
+}
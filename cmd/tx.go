@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	txNodeAddress string
+	txKeyFile     string
+)
+
+var txCmd = &cobra.Command{
+	Use:   "tx",
+	Short: "Befehle rund um einzelne Transaktionen",
+}
+
+var txStatusCmd = &cobra.Command{
+	Use:   "status <hash>",
+	Short: "Zeigt an, ob eine Transaktion wartet oder bereits in einem Block enthalten ist",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := newNodeClient(txNodeAddress, txKeyFile).GetTransaction(context.Background(), args[0])
+		if err != nil {
+			fmt.Println("Fehler beim Abrufen der Transaktion:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Transaktions-Hash: %x\n", status.Transaction.Hash)
+		fmt.Printf("Status: %s\n", status.Status)
+		if status.Transaction.Urgent {
+			fmt.Println("Dringend: ja")
+		}
+		if status.Block != nil {
+			fmt.Printf("Block: %d (%x)\n", status.Block.ID, status.Block.Hash)
+			fmt.Printf("Position im Block: %d\n", status.Position)
+			fmt.Printf("Blockzeit: %s\n", time.Unix(status.Block.Timestamp, 0).Format(time.RFC3339))
+			fmt.Printf("Bestätigungen: %d\n", status.Confirmations)
+		}
+	},
+}
+
+var txSubmitCmd = &cobra.Command{
+	Use:   "submit <datei>...",
+	Short: "Reicht mit create --offline erstellte Transaktionen ein",
+	Long: `Reicht signierte Transaktionsdateien in der angegebenen Reihenfolge ein. Nach der ersten
+Ablehnung wird abgebrochen, da spätere Nonces desselben Arztes sonst eine Lücke hätten.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		nodeClient := newNodeClient(txNodeAddress, txKeyFile)
+		for _, path := range args {
+			transaction, err := readTransactionFile(path)
+			if err != nil {
+				fmt.Printf("Fehler beim Lesen von %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			if err := nodeClient.AddTransaction(context.Background(), transaction); err != nil {
+				fmt.Printf("Fehler beim Senden von %s: %v\n", path, err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s: Transaktion %x eingereicht\n", path, transaction.Hash)
+		}
+	},
+}
+
+var txInspectCmd = &cobra.Command{
+	Use:   "inspect <datei>",
+	Short: "Prüft Hash und Signatur einer Transaktionsdatei und zeigt ihre Metadaten, ohne zu entschlüsseln",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		transaction, err := readTransactionFile(args[0])
+		if err != nil {
+			fmt.Println("Fehler beim Lesen der Transaktion:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Transaktions-Hash: %x\n", transaction.Hash)
+		fmt.Printf("Chain-ID: %s\n", transaction.ChainID)
+		fmt.Printf("Nonce: %d\n", transaction.Nonce)
+		fmt.Printf("Arzt: %s\n", blockchain.KeyID(transaction.Doctor))
+		fmt.Printf("Patient: %s\n", blockchain.KeyID(transaction.Patient))
+		if transaction.Urgent {
+			fmt.Println("Dringend: ja")
+		}
+		fmt.Printf("Verschlüsselte Daten: %d Bytes\n", len(transaction.EncryptedData.Ciphertext))
+
+		if err := verifyTransaction(transaction, chainID); err != nil {
+			fmt.Println("Prüfung fehlgeschlagen:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Hash und Signatur: gültig")
+	},
+}
+
+// writeTransactionFile speichert eine signierte Transaktion für tx submit
+func writeTransactionFile(path string, transaction *blockchain.Transaction) error {
+	data, err := json.MarshalIndent(transaction, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize transaction: %v", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+func readTransactionFile(path string) (*blockchain.Transaction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var transaction blockchain.Transaction
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&transaction); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction: %v", err)
+	}
+	return &transaction, nil
+}
+
+// verifyTransaction prüft die Schlüssel, die Chain-ID, den Hash und die Signatur des Arztes, ohne zu entschlüsseln
+func verifyTransaction(transaction *blockchain.Transaction, chainID string) error {
+	doctorPublicKey, err := utils.DeserializePublicKey(transaction.Doctor)
+	if err != nil {
+		return fmt.Errorf("invalid doctor public key: %v", err)
+	}
+	if _, err := utils.DeserializePublicKey(transaction.Patient); err != nil {
+		return fmt.Errorf("invalid patient public key: %v", err)
+	}
+	return transaction.ValidateTransaction(doctorPublicKey, chainID)
+}
+
+func init() {
+	txCmd.PersistentFlags().StringVarP(&txNodeAddress, "node_address", "a", "localhost:8080", "Adresse des Nodes")
+	txCmd.PersistentFlags().StringVarP(&txKeyFile, "key", "k", "", "Privater Schlüssel zum Signieren der Anfragen (Arzt, Patient oder Auditor)")
+
+	txCmd.AddCommand(txStatusCmd)
+	txCmd.AddCommand(txSubmitCmd)
+	txCmd.AddCommand(txInspectCmd)
+	rootCmd.AddCommand(txCmd)
+}
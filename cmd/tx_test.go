@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/stretchr/testify/require"
+)
+
+// Eine offline signierte Transaktion übersteht den Umweg über die Datei und wird später aufgenommen
+func TestOfflineTransactionFile(t *testing.T) {
+	authorityNode := newTestAuthorityNode(t)
+	doctor := newTestSigner(t)
+	patient := newTestSigner(t)
+
+	tx, err := blockchain.NewUrgentTransaction(testChainID, 0, "Allergy", "Penicillin", "", doctor, patient.PublicKey())
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "tx.json")
+	require.NoError(t, writeTransactionFile(path, tx))
+
+	loaded, err := readTransactionFile(path)
+	require.NoError(t, err)
+	require.Equal(t, tx, loaded)
+	require.NoError(t, verifyTransaction(loaded, testChainID))
+	require.ErrorContains(t, verifyTransaction(loaded, "other-chain"), "chain ID mismatch")
+
+	tampered := *loaded
+	tampered.Nonce = 1
+	require.ErrorContains(t, verifyTransaction(&tampered, testChainID), "hash mismatch")
+
+	require.NoError(t, authorityNode.AddTransaction(loaded))
+}
@@ -1,15 +1,15 @@
 package cmd
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"os"
 	"time"
 
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
 	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
 	"github.com/spf13/cobra"
 )
@@ -23,39 +23,32 @@ var viewCmd = &cobra.Command{
 	Use:   "view",
 	Short: "Zeigt alle Transaktionen eines Patienten an",
 	Run: func(cmd *cobra.Command, args []string) {
-		// Lade den privaten Schlüssel des Patienten
-		patientPrivKey, patientPubKey, err := utils.LoadPrivateKey(patientKeyFile)
-		if err != nil {
-			fmt.Println("Fehler beim Laden des privaten Schlüssels des Patienten:", err)
+		// Pflicht, darf aber auch aus der Konfiguration (client.key) kommen
+		if patientKeyFile == "" {
+			fmt.Println("Privater Schlüssel des Patienten fehlt: --key oder client.key in der Konfiguration angeben")
 			os.Exit(1)
 		}
 
-		// Serialisiere den öffentlichen Schlüssel des Patienten und kodiere ihn in Base64
-		serializedPubKey := utils.SerializePublicKey(patientPubKey)
-		patientID := base64.URLEncoding.EncodeToString(serializedPubKey)
-
-		// Baue die URL mit dem patientID-Parameter
-		url := fmt.Sprintf("http://%s/getPatientTransactions?patientID=%s", viewNodeAddress, patientID)
-
-		// Sende eine GET-Anfrage an den Authority Node
-		resp, err := http.Get(url)
+		// Lade den privaten Schlüssel des Patienten
+		patientSigner, err := utils.LoadSigner(patientKeyFile)
 		if err != nil {
-			fmt.Println("Fehler beim Abrufen der Transaktionen:", err)
+			fmt.Println("Fehler beim Laden des privaten Schlüssels des Patienten:", err)
 			os.Exit(1)
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := ioutil.ReadAll(resp.Body)
-			fmt.Printf("Fehlerhafte Antwort vom Server: %s\n", string(body))
+		// Anmeldung per Challenge beweist den Besitz des Schlüssels, Nodes ohne Authentifizierung kennen sie nicht
+		ctx := context.Background()
+		nodeClient := client.NewNodeClient(viewNodeAddress, clientOptions()...)
+		if err := nodeClient.Login(ctx, patientSigner, chainID); err != nil && !errors.Is(err, client.ErrNotFound) {
+			fmt.Println("Fehler bei der Anmeldung am Node:", err)
 			os.Exit(1)
 		}
 
-		// Lese die Antwort (die verschlüsselten Transaktionen)
-		var transactions []*blockchain.Transaction
-		err = json.NewDecoder(resp.Body).Decode(&transactions)
+		// Frage die Transaktionen beim Node (Authority oder Client) ab
+		serializedPubKey := utils.SerializePublicKey(patientSigner.PublicKey())
+		transactions, err := nodeClient.GetPatientTransactions(ctx, serializedPubKey)
 		if err != nil {
-			fmt.Println("Fehler beim Dekodieren der Transaktionen:", err)
+			fmt.Println("Fehler beim Abrufen der Transaktionen:", err)
 			os.Exit(1)
 		}
 
@@ -68,13 +61,7 @@ var viewCmd = &cobra.Command{
 				continue
 			}
 
-			// Konvertiere die Schlüssel zu ECDH-Schlüsseln
-			patientEcdhPrivKey, err := utils.EcdsaPrivToEcdh(patientPrivKey)
-			if err != nil {
-				fmt.Println("Fehler bei der Konvertierung des privaten Schlüssels des Patienten:", err)
-				continue
-			}
-
+			// Konvertiere den Schlüssel des Arztes zu einem ECDH-Schlüssel
 			doctorEcdhPubKey, err := utils.EcdsaPubToEcdh(doctorPubKey)
 			if err != nil {
 				fmt.Println("Fehler bei der Konvertierung des öffentlichen Schlüssels des Arztes:", err)
@@ -82,7 +69,7 @@ var viewCmd = &cobra.Command{
 			}
 
 			// Entschlüssele die Daten
-			plaintext, err := utils.DecryptData(patientEcdhPrivKey, doctorEcdhPubKey, tx.EncryptedData.Ciphertext, tx.EncryptedData.Nonce)
+			plaintext, err := utils.DecryptDataWithSigner(patientSigner, doctorEcdhPubKey, tx.EncryptedData.Ciphertext, tx.EncryptedData.Nonce)
 			if err != nil {
 				fmt.Println("Fehler beim Entschlüsseln der Transaktion:", err)
 				continue
@@ -109,8 +96,7 @@ var viewCmd = &cobra.Command{
 }
 
 func init() {
-	viewCmd.Flags().StringVarP(&viewNodeAddress, "node_address", "a", "localhost:8080", "Adresse des Authority Nodes")
+	viewCmd.Flags().StringVarP(&viewNodeAddress, "node_address", "a", "localhost:8080", "Adresse eines Nodes (Authority oder Client)")
 	viewCmd.Flags().StringVarP(&patientKeyFile, "key", "k", "", "Pfad zum privaten Schlüssel des Patienten (erforderlich)")
-	viewCmd.MarkFlagRequired("key")
 	rootCmd.AddCommand(viewCmd)
 }
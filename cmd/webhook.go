@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/client"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	webhookNodeAddress string
+	webhookPatientFile string
+	webhookSecret      string
+	webhookKeyFile     string
+)
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Webhooks für neue Patienteneinträge verwalten",
+}
+
+var webhookAddCmd = &cobra.Command{
+	Use:   "add <url>",
+	Short: "Registriert einen Webhook, der bei jedem neuen Eintrag des Patienten aufgerufen wird",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		patientPublicKey, err := utils.LoadPublicKey(webhookPatientFile)
+		if err != nil {
+			fmt.Println("Fehler beim Laden des Patienten-Public-Keys:", err)
+			os.Exit(1)
+		}
+
+		registered, err := newNodeClient(webhookNodeAddress, webhookKeyFile).RegisterWebhook(context.Background(), client.WebhookRegistration{
+			URL:       args[0],
+			PatientID: blockchain.KeyID(utils.SerializePublicKey(patientPublicKey)),
+			Secret:    webhookSecret,
+		})
+		if err != nil {
+			fmt.Println("Fehler beim Registrieren des Webhooks:", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Webhook registriert: %s\n", registered.ID)
+		fmt.Printf("Secret (wird nicht erneut angezeigt): %s\n", registered.Secret)
+	},
+}
+
+var webhookListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Listet alle registrierten Webhooks",
+	Run: func(cmd *cobra.Command, args []string) {
+		webhooks, err := newNodeClient(webhookNodeAddress, webhookKeyFile).ListWebhooks(context.Background())
+		if err != nil {
+			fmt.Println("Fehler beim Abrufen der Webhooks:", err)
+			os.Exit(1)
+		}
+
+		for _, webhook := range webhooks {
+			fmt.Printf("%s  %s  Patient: %s\n", webhook.ID, webhook.URL, webhook.PatientID)
+		}
+	},
+}
+
+var webhookRemoveCmd = &cobra.Command{
+	Use:   "remove <id>",
+	Short: "Entfernt einen Webhook",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := newNodeClient(webhookNodeAddress, webhookKeyFile).DeleteWebhook(context.Background(), args[0]); err != nil {
+			fmt.Println("Fehler beim Entfernen des Webhooks:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Webhook entfernt")
+	},
+}
+
+var webhookDeliveriesCmd = &cobra.Command{
+	Use:   "deliveries <id>",
+	Short: "Zeigt das Zustellprotokoll eines Webhooks",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		deliveries, err := newNodeClient(webhookNodeAddress, webhookKeyFile).GetWebhookDeliveries(context.Background(), args[0])
+		if err != nil {
+			fmt.Println("Fehler beim Abrufen des Zustellprotokolls:", err)
+			os.Exit(1)
+		}
+
+		for _, delivery := range deliveries {
+			fmt.Printf("%s  %s  Block %d  Tx %s  Versuche: %d",
+				time.Unix(delivery.UpdatedAt, 0).Format(time.RFC3339), delivery.Status, delivery.BlockID, delivery.TxHash, delivery.Attempts)
+			if delivery.LastError != "" {
+				fmt.Printf("  Fehler: %s", delivery.LastError)
+			}
+			fmt.Println()
+		}
+	},
+}
+
+func init() {
+	webhookCmd.PersistentFlags().StringVarP(&webhookNodeAddress, "node_address", "a", "localhost:8080", "Adresse des Nodes")
+	webhookCmd.PersistentFlags().StringVarP(&webhookKeyFile, "key", "k", "", "Privater Schlüssel des Patienten oder Operators zum Signieren der Anfragen")
+	webhookAddCmd.Flags().StringVarP(&webhookPatientFile, "patient", "p", "", "Pfad zum Public Key des Patienten")
+	webhookAddCmd.Flags().StringVar(&webhookSecret, "secret", "", "Secret für die HMAC-Signatur (leer: wird vom Node erzeugt)")
+	webhookAddCmd.MarkFlagRequired("patient")
+
+	webhookCmd.AddCommand(webhookAddCmd, webhookListCmd, webhookRemoveCmd, webhookDeliveriesCmd)
+	rootCmd.AddCommand(webhookCmd)
+}
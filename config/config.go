@@ -0,0 +1,299 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/auth"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix ist das Präfix der Umgebungsvariablen, die einzelne Werte der Datei überschreiben.
+// Der Name ergibt sich aus dem Pfad in der Datei, z.B. node.dataDir -> EGA_NODE_DATA_DIR.
+const EnvPrefix = "EGA"
+
+// Config ist die Konfigurationsdatei (YAML) für alle Befehle. Leere Felder behalten den Standardwert
+// des jeweiligen Flags, explizit gesetzte Flags haben Vorrang vor Umgebungsvariablen und Datei.
+type Config struct {
+	ChainID     string                 `yaml:"chainId"`
+	Node        NodeConfig             `yaml:"node"`
+	Client      ClientConfig           `yaml:"client"`
+	Trust       TrustConfig            `yaml:"trust"`
+	TLS         TLSConfig              `yaml:"tls"`
+	BlockPolicy blockchain.BlockPolicy `yaml:"blockPolicy"` // nur Authority Node, Nullwerte: Standard-Policy
+	Pool        blockchain.PoolLimits  `yaml:"pool"`        // nur Authority Node, Nullwerte: Standardgrenzen
+	Log         LogConfig              `yaml:"log"`
+}
+
+// NodeConfig gilt für den Befehl "node"
+type NodeConfig struct {
+	Listen     string   `yaml:"listen"`     // Adresse der HTTP-API, z.B. ":8080"
+	GRPCListen string   `yaml:"grpcListen"` // Adresse der gRPC-API, leer: deaktiviert
+	DataDir    string   `yaml:"dataDir"`    // leer: nur im Speicher
+	Key        string   `yaml:"key"`        // Schlüssel des Nodes (PEM, Keystore oder pkcs11:-URI)
+	Authority  string   `yaml:"authority"`  // Adresse des Authority Nodes, leer: der Node ist selbst Authority
+	Peers      []string `yaml:"peers"`      // weitere Nodes, von denen ein Client Node Blöcke übernehmen kann
+	Roles      string   `yaml:"roles"`      // Rollendatei, aktiviert die Authentifizierung
+	// Webhooks an interne Adressen (Loopback, private Netze, Link-Local) erlauben
+	WebhookAllowPrivate bool `yaml:"webhookAllowPrivate"`
+}
+
+// ClientConfig gilt für die Befehle, die einen Node ansprechen (create, view, tx, webhook, reindex)
+type ClientConfig struct {
+	NodeAddress string `yaml:"nodeAddress"`
+	Key         string `yaml:"key"` // eigener Schlüssel für signierte Anfragen
+}
+
+// TrustConfig legt die Vertrauensanker eines Client Nodes fest
+type TrustConfig struct {
+	AuthorityKeys []string `yaml:"authorityKeys"` // Public Keys (PEM) der vertrauenswürdigen Authority
+	GenesisHash   string   `yaml:"genesisHash"`   // hex
+	// Ohne Schlüssel und Genesis-Hash den Schlüssel des Authority Nodes ungeprüft übernehmen (nur zum Testen)
+	InsecureFirstUse bool `yaml:"insecureFirstUse"`
+}
+
+type TLSConfig struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	CA   string `yaml:"ca"`
+	MTLS bool   `yaml:"mtls"` // Client-Zertifikate verlangen (nur Node)
+}
+
+type LogConfig struct {
+	File string `yaml:"file"` // Ausgabe des Nodes an diese Datei anhängen, leer: Konsole
+}
+
+// Load liest die Konfigurationsdatei. Unbekannte Schlüssel sind ein Fehler, damit Tippfehler auffallen.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to decode config %s: %v", path, err)
+	}
+	return &config, nil
+}
+
+// ApplyEnv überschreibt die Werte mit gesetzten Umgebungsvariablen (EGA_...). Listen werden
+// kommagetrennt angegeben. lookup ist in der Regel os.LookupEnv.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	return applyEnv(reflect.ValueOf(c).Elem(), EnvPrefix, lookup)
+}
+
+// EnvNames liefert die Namen aller unterstützten Umgebungsvariablen
+func EnvNames() []string {
+	var names []string
+	applyEnv(reflect.ValueOf(&Config{}).Elem(), EnvPrefix, func(name string) (string, bool) {
+		names = append(names, name)
+		return "", false
+	})
+	return names
+}
+
+func applyEnv(value reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		name := prefix + "_" + envName(strings.Split(value.Type().Field(i).Tag.Get("yaml"), ",")[0])
+
+		if _, ok := field.Addr().Interface().(encoding.TextUnmarshaler); !ok && field.Kind() == reflect.Struct {
+			if err := applyEnv(field, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		text, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(field, text); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setValue(field reflect.Value, text string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(value)
+	case reflect.Int:
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(value))
+	case reflect.Slice:
+		var values []string
+		for _, value := range strings.Split(text, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// envName wandelt einen YAML-Schlüssel in camelCase in den Teil einer Umgebungsvariable um (dataDir -> DATA_DIR)
+func envName(key string) string {
+	var name strings.Builder
+	for i, r := range key {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(key[i-1])) {
+			name.WriteByte('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
+
+// Policy liefert die Block-Policy, nicht gesetzte Felder haben den Standardwert
+func (c *Config) Policy() blockchain.BlockPolicy {
+	policy := blockchain.DefaultBlockPolicy()
+	if c.BlockPolicy.MaxTransactions != 0 {
+		policy.MaxTransactions = c.BlockPolicy.MaxTransactions
+	}
+	if c.BlockPolicy.MaxBlockBytes != 0 {
+		policy.MaxBlockBytes = c.BlockPolicy.MaxBlockBytes
+	}
+	if c.BlockPolicy.MinInterval != 0 {
+		policy.MinInterval = c.BlockPolicy.MinInterval
+	}
+	if c.BlockPolicy.MaxInterval != 0 {
+		policy.MaxInterval = c.BlockPolicy.MaxInterval
+	}
+	if c.BlockPolicy.EmptyBlocks {
+		policy.EmptyBlocks = true
+	}
+	return policy
+}
+
+// PoolLimits liefert die Grenzen des Transaktionspools, nicht gesetzte Felder mit Standardwert
+func (c *Config) PoolLimits() blockchain.PoolLimits {
+	limits := blockchain.DefaultPoolLimits()
+	if c.Pool.MaxTransactions != 0 {
+		limits.MaxTransactions = c.Pool.MaxTransactions
+	}
+	if c.Pool.MaxPerDoctor != 0 {
+		limits.MaxPerDoctor = c.Pool.MaxPerDoctor
+	}
+	if c.Pool.TTL != 0 {
+		limits.TTL = c.Pool.TTL
+	}
+	return limits
+}
+
+// Validate prüft die Konfiguration einschließlich der referenzierten Dateien und liefert alle Fehler auf einmal
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	check(validateAddress("node.listen", c.Node.Listen))
+	check(validateAddress("node.grpcListen", c.Node.GRPCListen))
+	check(validateKey("node.key", c.Node.Key))
+	check(validateKey("client.key", c.Client.Key))
+
+	if c.Node.Roles != "" {
+		if _, err := auth.LoadRoleConfig(c.Node.Roles); err != nil {
+			errs = append(errs, fmt.Errorf("node.roles: %v", err))
+		}
+	}
+
+	// Blöcke von Peers lassen sich nur gegen festgelegte Authority-Schlüssel prüfen
+	if len(c.Node.Peers) > 0 {
+		if c.Node.Authority == "" {
+			errs = append(errs, fmt.Errorf("node.peers: only client nodes (node.authority) sync from peers"))
+		}
+		if len(c.Trust.AuthorityKeys) == 0 {
+			errs = append(errs, fmt.Errorf("node.peers: requires trust.authorityKeys to verify blocks from peers"))
+		}
+	}
+
+	for _, keyFile := range c.Trust.AuthorityKeys {
+		if _, err := utils.LoadPublicKey(keyFile); err != nil {
+			errs = append(errs, fmt.Errorf("trust.authorityKeys: %s: %v", keyFile, err))
+		}
+	}
+	if c.Trust.GenesisHash != "" {
+		if _, err := hex.DecodeString(c.Trust.GenesisHash); err != nil {
+			errs = append(errs, fmt.Errorf("trust.genesisHash: %v", err))
+		}
+	}
+
+	files := utils.TLSFiles{CertFile: c.TLS.Cert, KeyFile: c.TLS.Key, CAFile: c.TLS.CA}
+	if c.TLS.MTLS {
+		if _, err := utils.ServerTLSConfig(files, true); err != nil {
+			errs = append(errs, fmt.Errorf("tls: %v", err))
+		}
+	} else if files.Enabled() {
+		if _, err := utils.ClientTLSConfig(files); err != nil {
+			errs = append(errs, fmt.Errorf("tls: %v", err))
+		}
+	}
+
+	check(c.Policy().Validate())
+	check(c.PoolLimits().Validate())
+
+	if c.Log.File != "" {
+		if _, err := os.Stat(filepath.Dir(c.Log.File)); err != nil {
+			errs = append(errs, fmt.Errorf("log.file: %v", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateAddress(name, address string) error {
+	if address == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// validateKey prüft nur, ob die Schlüsseldatei lesbar ist: Keystore und HSM brauchen erst beim Start ihre PIN bzw. Passphrase
+func validateKey(name, keyRef string) error {
+	if keyRef == "" || strings.HasPrefix(keyRef, "pkcs11:") {
+		return nil
+	}
+	if _, err := os.Stat(keyRef); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "ega.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadWithEnvOverrides(t *testing.T) {
+	path := writeConfig(t, `
+chainId: ega-test
+node:
+  listen: ":9000"
+  authority: localhost:8080
+  peers: [localhost:8082]
+blockPolicy:
+  maxTransactions: 50
+  minInterval: 10s
+`)
+
+	config, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, "ega-test", config.ChainID)
+	require.Equal(t, []string{"localhost:8082"}, config.Node.Peers)
+	require.Equal(t, blockchain.Duration(10*time.Second), config.BlockPolicy.MinInterval)
+
+	env := map[string]string{
+		"EGA_NODE_LISTEN":               ":9001",
+		"EGA_NODE_PEERS":                "localhost:8083, localhost:8084",
+		"EGA_BLOCK_POLICY_MAX_INTERVAL": "1m",
+		"EGA_TLS_MTLS":                  "true",
+	}
+	require.NoError(t, config.ApplyEnv(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}))
+	require.Equal(t, ":9001", config.Node.Listen)
+	require.Equal(t, []string{"localhost:8083", "localhost:8084"}, config.Node.Peers)
+	require.True(t, config.TLS.MTLS)
+
+	// Nicht gesetzte Felder der Policy behalten den Standardwert
+	policy := config.Policy()
+	require.Equal(t, 50, policy.MaxTransactions)
+	require.Equal(t, blockchain.Duration(time.Minute), policy.MaxInterval)
+	require.Equal(t, blockchain.DefaultBlockPolicy().MaxBlockBytes, policy.MaxBlockBytes)
+
+	require.Error(t, config.ApplyEnv(func(name string) (string, bool) {
+		return "soon", name == "EGA_BLOCK_POLICY_MIN_INTERVAL"
+	}))
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	_, err := Load(writeConfig(t, "node:\n  lisen: \":9000\"\n"))
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	config := &Config{}
+	require.NoError(t, config.Validate())
+
+	config.Node.Listen = "9000"
+	config.Node.Peers = []string{"localhost:8082"}
+	config.Trust.GenesisHash = "xyz"
+	config.BlockPolicy.MinInterval = blockchain.Duration(time.Hour)
+	err := config.Validate()
+	require.Error(t, err)
+	for _, field := range []string{"node.listen", "node.peers", "trust.genesisHash", "minInterval"} {
+		require.Contains(t, err.Error(), field)
+	}
+}
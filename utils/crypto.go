@@ -84,12 +84,54 @@ func EncryptData(senderPrivKey *ecdh.PrivateKey, recipientPubKey *ecdh.PublicKey
 		return nil, nil, fmt.Errorf("ECDH key exchange failed: %v", err)
 	}
 
+	return encryptWithSharedSecret(sharedSecret, plaintext)
+}
+
+// EncryptDataWithSigner verschlüsselt wie EncryptData, leitet das ECDH-Geheimnis aber über den Signer ab
+func EncryptDataWithSigner(sender Signer, recipientPubKey *ecdh.PublicKey, plaintext []byte) ([]byte, []byte, error) {
+	sharedSecret, err := sender.ECDH(recipientPubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ECDH key exchange failed: %v", err)
+	}
+
+	return encryptWithSharedSecret(sharedSecret, plaintext)
+}
+
+func DecryptData(recipientPrivKey *ecdh.PrivateKey, senderPubKey *ecdh.PublicKey, ciphertext, nonce []byte) ([]byte, error) {
+	// Perform ECDH key exchange to derive the shared secret
+	sharedSecret, err := recipientPrivKey.ECDH(senderPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("ECDH key exchange failed: %v", err)
+	}
+
+	return decryptWithSharedSecret(sharedSecret, ciphertext, nonce)
+}
+
+// DecryptDataWithSigner entschlüsselt wie DecryptData, leitet das ECDH-Geheimnis aber über den Signer ab
+func DecryptDataWithSigner(recipient Signer, senderPubKey *ecdh.PublicKey, ciphertext, nonce []byte) ([]byte, error) {
+	sharedSecret, err := recipient.ECDH(senderPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("ECDH key exchange failed: %v", err)
+	}
+
+	return decryptWithSharedSecret(sharedSecret, ciphertext, nonce)
+}
+
+func deriveSymmetricKey(sharedSecret []byte) ([]byte, error) {
 	// Derive symmetric key using HKDF
 	salt := []byte("ECDH encryption")
 	info := []byte("encryption key")
 	hkdf := hkdf.New(sha256.New, sharedSecret, salt, info)
 	symmetricKey := make([]byte, 32)
 	if _, err := io.ReadFull(hkdf, symmetricKey); err != nil {
+		return nil, err
+	}
+	return symmetricKey, nil
+}
+
+func encryptWithSharedSecret(sharedSecret, plaintext []byte) ([]byte, []byte, error) {
+	symmetricKey, err := deriveSymmetricKey(sharedSecret)
+	if err != nil {
 		return nil, nil, err
 	}
 
@@ -110,19 +152,9 @@ func EncryptData(senderPrivKey *ecdh.PrivateKey, recipientPubKey *ecdh.PublicKey
 	return ciphertext, nonce, nil
 }
 
-func DecryptData(recipientPrivKey *ecdh.PrivateKey, senderPubKey *ecdh.PublicKey, ciphertext, nonce []byte) ([]byte, error) {
-	// Perform ECDH key exchange to derive the shared secret
-	sharedSecret, err := recipientPrivKey.ECDH(senderPubKey)
+func decryptWithSharedSecret(sharedSecret, ciphertext, nonce []byte) ([]byte, error) {
+	symmetricKey, err := deriveSymmetricKey(sharedSecret)
 	if err != nil {
-		return nil, fmt.Errorf("ECDH key exchange failed: %v", err)
-	}
-
-	// Derive symmetric key using HKDF
-	salt := []byte("ECDH encryption")
-	info := []byte("encryption key")
-	hkdf := hkdf.New(sha256.New, sharedSecret, salt, info)
-	symmetricKey := make([]byte, 32)
-	if _, err := io.ReadFull(hkdf, symmetricKey); err != nil {
 		return nil, err
 	}
 
@@ -142,26 +174,9 @@ func DecryptData(recipientPrivKey *ecdh.PrivateKey, senderPubKey *ecdh.PublicKey
 	return plaintext, nil
 }
 
-func SignTransaction(senderPrivKey *ecdsa.PrivateKey, transactionData []byte) ([]byte, []byte, error) {
-	hash := sha256.Sum256(transactionData)
-	r, s, err := ecdsa.Sign(rand.Reader, senderPrivKey, hash[:])
-	if err != nil {
-		return nil, nil, err
-	}
-	return r.Bytes(), s.Bytes(), nil
-}
-
-func VerifySignature(senderPubKey *ecdsa.PublicKey, transactionData, rBytes, sBytes []byte) bool {
-	hash := sha256.Sum256(transactionData)
-	var r, s big.Int
-	r.SetBytes(rBytes)
-	s.SetBytes(sBytes)
-	return ecdsa.Verify(senderPubKey, hash[:], &r, &s)
-}
-
 func EcdsaPrivToEcdh(ecdsaPrivKey *ecdsa.PrivateKey) (*ecdh.PrivateKey, error) {
 	ecdhCurve := ecdh.P256()
-	ecdhPrivKey, err := ecdhCurve.NewPrivateKey(ecdsaPrivKey.D.Bytes())
+	ecdhPrivKey, err := ecdhCurve.NewPrivateKey(ecdsaPrivKey.D.FillBytes(make([]byte, 32)))
 	if err != nil {
 		fmt.Println("Error converting ECDSA private key to ECDH private key:", err)
 		return nil, err
@@ -183,8 +198,13 @@ func EcdsaPubToEcdh(ecdsaPubKey *ecdsa.PublicKey) (*ecdh.PublicKey, error) {
 
 // Serialize the ECDSA public key in uncompressed form (X and Y coordinates concatenated)
 func SerializePublicKey(pubKey *ecdsa.PublicKey) []byte {
-	// Uncompressed public key format: 0x04 || X || Y
-	return append([]byte{0x04}, append(pubKey.X.Bytes(), pubKey.Y.Bytes()...)...)
+	// Uncompressed public key format: 0x04 || X || Y, coordinates padded to the field size
+	coordinateLength := (pubKey.Curve.Params().BitSize + 7) / 8
+	serialized := make([]byte, 1+2*coordinateLength)
+	serialized[0] = 0x04
+	pubKey.X.FillBytes(serialized[1 : 1+coordinateLength])
+	pubKey.Y.FillBytes(serialized[1+coordinateLength:])
+	return serialized
 }
 
 // Deserialize a public key from uncompressed bytes and return an *ecdsa.PublicKey
@@ -203,6 +223,11 @@ func DeserializePublicKey(pubKeyBytes []byte) (*ecdsa.PublicKey, error) {
 		return nil, fmt.Errorf("invalid public key length")
 	}
 
+	// Punkte außerhalb der Kurve ablehnen, Schlüssel stammen z.B. aus Anfragen Dritter
+	if _, err := ecdh.P256().NewPublicKey(pubKeyBytes); err != nil {
+		return nil, fmt.Errorf("public key is not a valid P-256 point")
+	}
+
 	xBytes := pubKeyBytes[1 : 1+coordinateLength]
 	yBytes := pubKeyBytes[1+coordinateLength:]
 
@@ -127,12 +127,17 @@ func TestSignVerifyTransaction(t *testing.T) {
 
 	// Prepare a sample transaction (this could be any data, such as transaction data)
 	transactionData := []byte("Transaction data to sign")
+	digest := DomainHash(DomainTransaction, "ega-test", transactionData)
 
 	// Sign the transaction
-	rBytes, sBytes, err := SignTransaction(senderPrivKey, transactionData)
+	rBytes, sBytes, err := SignDigest(NewMemorySigner(senderPrivKey), digest)
 	require.NoError(t, err, "Error during transaction signing")
 
 	// Verify the signature using the sender's public key
-	isValid := VerifySignature(&senderPrivKey.PublicKey, transactionData, rBytes, sBytes)
+	isValid := VerifyDigest(&senderPrivKey.PublicKey, digest, rBytes, sBytes)
 	require.True(t, isValid, "Signature verification failed")
+
+	// The same signature must not be valid in another domain or on another chain
+	require.False(t, VerifyDigest(&senderPrivKey.PublicKey, DomainHash(DomainBlockHeader, "ega-test", transactionData), rBytes, sBytes))
+	require.False(t, VerifyDigest(&senderPrivKey.PublicKey, DomainHash(DomainTransaction, "ega-other", transactionData), rBytes, sBytes))
 }
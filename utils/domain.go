@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// SignatureDomain trennt die Signaturen verschiedener Objekttypen voneinander,
+// damit z.B. eine Blocksignatur nicht als Transaktionssignatur wiederverwendet werden kann
+type SignatureDomain string
+
+const (
+	DomainBlockHeader SignatureDomain = "EGA/block-header/v1"
+	DomainTransaction SignatureDomain = "EGA/transaction/v1"
+	DomainConsent     SignatureDomain = "EGA/consent/v1"
+	DomainVote        SignatureDomain = "EGA/vote/v1"
+	DomainRequest     SignatureDomain = "EGA/request/v1"        // signierte HTTP-Anfragen
+	DomainChallenge   SignatureDomain = "EGA/auth-challenge/v1" // Schlüsselnachweis beim Login
+)
+
+// DomainHash berechnet SHA-256 über Domain-Tag, Chain-ID und Nutzdaten.
+// Tag und Chain-ID werden mit Längenpräfix kodiert, damit die Eingabe eindeutig ist.
+// Das Ergebnis wird direkt signiert (SignDigest), ohne erneut gehasht zu werden.
+func DomainHash(domain SignatureDomain, chainID string, payload []byte) []byte {
+	h := sha256.New()
+	writeLengthPrefixed(h, []byte(domain))
+	writeLengthPrefixed(h, []byte(chainID))
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+func writeLengthPrefixed(h interface{ Write([]byte) (int, error) }, data []byte) {
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(data)))
+	h.Write(length[:])
+	h.Write(data)
+}
+
+// SignDigest signiert einen 32-Byte-Digest unverändert und liefert R und S in kanonischer Form (32 Bytes, low-S)
+func SignDigest(signer Signer, digest []byte) ([]byte, []byte, error) {
+	if len(digest) != sha256.Size {
+		return nil, nil, fmt.Errorf("invalid digest length %d", len(digest))
+	}
+
+	r, s, err := signer.Sign(digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NormalizeSignature(r, s)
+}
+
+// VerifyDigest akzeptiert nur kanonische Signaturen, high-S oder falsch kodierte Werte werden abgelehnt
+func VerifyDigest(senderPubKey *ecdsa.PublicKey, digest, rBytes, sBytes []byte) bool {
+	if len(digest) != sha256.Size || !IsCanonicalSignature(rBytes, sBytes) {
+		return false
+	}
+
+	var r, s big.Int
+	r.SetBytes(rBytes)
+	s.SetBytes(sBytes)
+	return ecdsa.Verify(senderPubKey, digest, &r, &s)
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keystoreVersion = 1
+	keystoreKDF     = "scrypt"
+)
+
+// Standardparameter für scrypt (interaktive Nutzung)
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// keystoreFile ist das JSON-Format eines passwortgeschützten Schlüssels
+type keystoreFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+	PublicKey  []byte `json:"publicKey"`
+}
+
+// IsKeystore prüft, ob die Daten ein verschlüsselter Keystore sind
+func IsKeystore(data []byte) bool {
+	var ks keystoreFile
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return false
+	}
+	return ks.KDF == keystoreKDF && len(ks.Ciphertext) > 0
+}
+
+// EncryptKeystore verschlüsselt einen privaten Schlüssel mit einer Passphrase
+func EncryptKeystore(privateKey *ecdsa.PrivateKey, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal EC private key: %v", err)
+	}
+	defer zeroBytes(der)
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	ks := keystoreFile{
+		Version:   keystoreVersion,
+		KDF:       keystoreKDF,
+		Salt:      salt,
+		N:         scryptN,
+		R:         scryptR,
+		P:         scryptP,
+		PublicKey: SerializePublicKey(&privateKey.PublicKey),
+	}
+
+	aesGCM, err := ks.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.Nonce = make([]byte, aesGCM.NonceSize())
+	if _, err := rand.Read(ks.Nonce); err != nil {
+		return nil, err
+	}
+	// Der öffentliche Schlüssel wird als Associated Data gebunden
+	ks.Ciphertext = aesGCM.Seal(nil, ks.Nonce, der, ks.PublicKey)
+
+	return json.MarshalIndent(ks, "", "  ")
+}
+
+func (ks *keystoreFile) cipher(passphrase string) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), ks.Salt, ks.N, ks.R, ks.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive keystore key: %v", err)
+	}
+	defer zeroBytes(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeystoreSigner hält den Schlüssel nur verschlüsselt im Speicher und
+// entschlüsselt ihn ausschließlich für die Dauer einer Operation
+type KeystoreSigner struct {
+	keystore  keystoreFile
+	aead      cipher.AEAD
+	publicKey *ecdsa.PublicKey
+}
+
+func NewKeystoreSigner(data []byte, passphrase string) (*KeystoreSigner, error) {
+	var ks keystoreFile
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("failed to decode keystore: %v", err)
+	}
+	if ks.Version != keystoreVersion || ks.KDF != keystoreKDF {
+		return nil, fmt.Errorf("unsupported keystore version %d (%s)", ks.Version, ks.KDF)
+	}
+
+	publicKey, err := DeserializePublicKey(ks.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore public key: %v", err)
+	}
+
+	aead, err := ks.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &KeystoreSigner{keystore: ks, aead: aead, publicKey: publicKey}
+
+	// Passphrase sofort prüfen, statt erst beim ersten Signieren zu scheitern
+	if err := signer.withPrivateKey(func(*ecdsa.PrivateKey) error { return nil }); err != nil {
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+func (k *KeystoreSigner) withPrivateKey(fn func(*ecdsa.PrivateKey) error) error {
+	der, err := k.aead.Open(nil, k.keystore.Nonce, k.keystore.Ciphertext, k.keystore.PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt keystore (wrong passphrase?)")
+	}
+	defer zeroBytes(der)
+
+	privateKey, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse EC private key: %v", err)
+	}
+	defer privateKey.D.SetInt64(0)
+
+	return fn(privateKey)
+}
+
+func (k *KeystoreSigner) Sign(digest []byte) (r []byte, s []byte, err error) {
+	err = k.withPrivateKey(func(privateKey *ecdsa.PrivateKey) error {
+		r, s, err = NewMemorySigner(privateKey).Sign(digest)
+		return err
+	})
+	return r, s, err
+}
+
+func (k *KeystoreSigner) PublicKey() *ecdsa.PublicKey {
+	return k.publicKey
+}
+
+func (k *KeystoreSigner) ECDH(remote *ecdh.PublicKey) (secret []byte, err error) {
+	err = k.withPrivateKey(func(privateKey *ecdsa.PrivateKey) error {
+		secret, err = NewMemorySigner(privateKey).ECDH(remote)
+		return err
+	})
+	return secret, err
+}
+
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,203 @@
+//go:build cgo
+
+package utils
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS11Signer signiert und leitet ECDH-Geheimnisse direkt im Token ab,
+// der private Schlüssel verlässt das HSM nie
+type PKCS11Signer struct {
+	ctx        *pkcs11.Ctx
+	session    pkcs11.SessionHandle
+	privateKey pkcs11.ObjectHandle
+	publicKey  *ecdsa.PublicKey
+	mutex      sync.Mutex // PKCS#11-Sessions sind nicht threadsicher
+}
+
+func NewPKCS11Signer(config PKCS11Config) (*PKCS11Signer, error) {
+	if config.Module == "" || config.TokenLabel == "" || config.KeyLabel == "" {
+		return nil, fmt.Errorf("pkcs11: module, token and object label are required")
+	}
+
+	ctx := pkcs11.New(config.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("pkcs11: failed to load module %s", config.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("pkcs11: failed to initialize module: %v", err)
+	}
+
+	signer := &PKCS11Signer{ctx: ctx}
+	if err := signer.open(config); err != nil {
+		signer.Close()
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+func (p *PKCS11Signer) open(config PKCS11Config) error {
+	slot, err := p.findSlot(config.TokenLabel)
+	if err != nil {
+		return err
+	}
+
+	p.session, err = p.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("pkcs11: failed to open session: %v", err)
+	}
+
+	if err := p.ctx.Login(p.session, pkcs11.CKU_USER, config.PIN); err != nil {
+		return fmt.Errorf("pkcs11: login failed: %v", err)
+	}
+
+	p.privateKey, err = p.findObject(pkcs11.CKO_PRIVATE_KEY, config.KeyLabel)
+	if err != nil {
+		return err
+	}
+
+	publicKeyHandle, err := p.findObject(pkcs11.CKO_PUBLIC_KEY, config.KeyLabel)
+	if err != nil {
+		return err
+	}
+
+	p.publicKey, err = p.readPublicKey(publicKeyHandle)
+	return err
+}
+
+func (p *PKCS11Signer) findSlot(tokenLabel string) (uint, error) {
+	slots, err := p.ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("pkcs11: failed to list slots: %v", err)
+	}
+
+	for _, slot := range slots {
+		info, err := p.ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if info.Label == tokenLabel {
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("pkcs11: token %q not found", tokenLabel)
+}
+
+func (p *PKCS11Signer) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := p.ctx.FindObjectsInit(p.session, template); err != nil {
+		return 0, fmt.Errorf("pkcs11: failed to search objects: %v", err)
+	}
+	defer p.ctx.FindObjectsFinal(p.session)
+
+	objects, _, err := p.ctx.FindObjects(p.session, 1)
+	if err != nil {
+		return 0, fmt.Errorf("pkcs11: failed to search objects: %v", err)
+	}
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("pkcs11: no EC key with label %q found", label)
+	}
+
+	return objects[0], nil
+}
+
+func (p *PKCS11Signer) readPublicKey(handle pkcs11.ObjectHandle) (*ecdsa.PublicKey, error) {
+	attributes, err := p.ctx.GetAttributeValue(p.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: failed to read public key: %v", err)
+	}
+
+	// CKA_EC_POINT ist ein DER-kodierter OCTET STRING mit dem unkomprimierten Punkt
+	var point []byte
+	if _, err := asn1.Unmarshal(attributes[0].Value, &point); err != nil {
+		return nil, fmt.Errorf("pkcs11: failed to decode EC point: %v", err)
+	}
+
+	return DeserializePublicKey(point)
+}
+
+func (p *PKCS11Signer) Sign(digest []byte) ([]byte, []byte, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}
+	if err := p.ctx.SignInit(p.session, mechanism, p.privateKey); err != nil {
+		return nil, nil, fmt.Errorf("pkcs11: sign init failed: %v", err)
+	}
+
+	signature, err := p.ctx.Sign(p.session, digest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("pkcs11: sign failed: %v", err)
+	}
+	if len(signature)%2 != 0 {
+		return nil, nil, fmt.Errorf("pkcs11: unexpected signature length %d", len(signature))
+	}
+
+	// Das Token liefert R || S mit fester Breite
+	half := len(signature) / 2
+	return signature[:half], signature[half:], nil
+}
+
+func (p *PKCS11Signer) PublicKey() *ecdsa.PublicKey {
+	return p.publicKey
+}
+
+func (p *PKCS11Signer) ECDH(remote *ecdh.PublicKey) ([]byte, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	params := pkcs11.NewECDH1DeriveParams(pkcs11.CKD_NULL, nil, remote.Bytes())
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDH1_DERIVE, params)}
+
+	// Das abgeleitete Geheimnis ist ein Session-Objekt, das nur zum Auslesen existiert
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+	}
+
+	secretHandle, err := p.ctx.DeriveKey(p.session, mechanism, p.privateKey, template)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: ECDH derive failed: %v", err)
+	}
+	defer p.ctx.DestroyObject(p.session, secretHandle)
+
+	attributes, err := p.ctx.GetAttributeValue(p.session, secretHandle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: failed to read derived secret: %v", err)
+	}
+
+	return attributes[0].Value, nil
+}
+
+// Close meldet sich vom Token ab und gibt das Modul frei
+func (p *PKCS11Signer) Close() error {
+	if p.session != 0 {
+		p.ctx.Logout(p.session)
+		p.ctx.CloseSession(p.session)
+	}
+	p.ctx.Finalize()
+	p.ctx.Destroy()
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !cgo
+
+package utils
+
+import "fmt"
+
+// PKCS11Signer ist ohne cgo nicht verfügbar
+type PKCS11Signer struct {
+	MemorySigner
+}
+
+func NewPKCS11Signer(config PKCS11Config) (*PKCS11Signer, error) {
+	return nil, fmt.Errorf("pkcs11 support requires a build with cgo enabled")
+}
+
+func (p *PKCS11Signer) Close() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PKCS11Config beschreibt, wo ein Schlüssel in einem PKCS#11-Token liegt
+type PKCS11Config struct {
+	Module     string // Pfad zur PKCS#11-Bibliothek, z.B. libsofthsm2.so
+	TokenLabel string
+	KeyLabel   string
+	PIN        string
+}
+
+// ParsePKCS11URI liest eine PKCS#11-URI nach RFC 7512, z.B.
+// pkcs11:token=ega;object=authority?module-path=/usr/lib/softhsm/libsofthsm2.so
+func ParsePKCS11URI(uri string) (PKCS11Config, error) {
+	var config PKCS11Config
+
+	rest, ok := strings.CutPrefix(uri, "pkcs11:")
+	if !ok {
+		return config, fmt.Errorf("not a pkcs11 URI: %s", uri)
+	}
+
+	path, query, _ := strings.Cut(rest, "?")
+
+	attributes := map[string]string{}
+	for _, part := range strings.Split(path, ";") {
+		if err := parsePKCS11Attribute(part, attributes); err != nil {
+			return config, err
+		}
+	}
+	for _, part := range strings.Split(query, "&") {
+		if err := parsePKCS11Attribute(part, attributes); err != nil {
+			return config, err
+		}
+	}
+
+	config.Module = attributes["module-path"]
+	config.TokenLabel = attributes["token"]
+	config.KeyLabel = attributes["object"]
+	config.PIN = attributes["pin-value"]
+
+	if config.Module == "" || config.TokenLabel == "" || config.KeyLabel == "" {
+		return config, fmt.Errorf("pkcs11 URI requires token, object and module-path")
+	}
+
+	return config, nil
+}
+
+func parsePKCS11Attribute(part string, attributes map[string]string) error {
+	if part == "" {
+		return nil
+	}
+
+	key, value, ok := strings.Cut(part, "=")
+	if !ok {
+		return fmt.Errorf("invalid pkcs11 URI attribute %q", part)
+	}
+
+	decoded, err := url.PathUnescape(value)
+	if err != nil {
+		return fmt.Errorf("invalid pkcs11 URI attribute %q: %v", part, err)
+	}
+
+	attributes[key] = decoded
+	return nil
+}
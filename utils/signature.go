@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// SignatureScalarSize ist die feste Breite von R und S in Bytes (P-256)
+const SignatureScalarSize = 32
+
+var (
+	curveOrder     = elliptic.P256().Params().N
+	halfCurveOrder = new(big.Int).Rsh(curveOrder, 1)
+)
+
+// signDeterministic erzeugt eine ECDSA-Signatur mit deterministischer Nonce nach RFC 6979
+// (HMAC-DRBG mit SHA-256). Gleicher Schlüssel und gleicher Digest ergeben stets dieselbe Signatur.
+// Ohne Zufallsquelle signiert crypto/ecdsa deterministisch und rechnet mit Schlüssel und Nonce in
+// konstanter Zeit.
+func signDeterministic(privateKey *ecdsa.PrivateKey, digest []byte) (*big.Int, *big.Int, error) {
+	if privateKey.Curve != elliptic.P256() {
+		return nil, nil, fmt.Errorf("unsupported curve %s", privateKey.Curve.Params().Name)
+	}
+
+	signature, err := privateKey.Sign(nil, digest, crypto.SHA256)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign digest: %v", err)
+	}
+
+	var decoded struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(signature, &decoded); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode signature: %v", err)
+	}
+	return decoded.R, decoded.S, nil
+}
+
+// NormalizeSignature bringt eine Signatur in die kanonische Form: S liegt in der
+// unteren Hälfte der Gruppenordnung (low-S) und R und S haben jeweils genau 32 Bytes.
+// Damit ist die Signatur nicht mehr formbar (s und n-s wären sonst beide gültig).
+func NormalizeSignature(rBytes, sBytes []byte) ([]byte, []byte, error) {
+	r := new(big.Int).SetBytes(rBytes)
+	s := new(big.Int).SetBytes(sBytes)
+
+	if r.Sign() == 0 || r.Cmp(curveOrder) >= 0 || s.Sign() == 0 || s.Cmp(curveOrder) >= 0 {
+		return nil, nil, fmt.Errorf("signature values out of range")
+	}
+
+	if s.Cmp(halfCurveOrder) > 0 {
+		s.Sub(curveOrder, s)
+	}
+
+	return r.FillBytes(make([]byte, SignatureScalarSize)), s.FillBytes(make([]byte, SignatureScalarSize)), nil
+}
+
+// IsCanonicalSignature prüft feste Breite, Wertebereich und low-S
+func IsCanonicalSignature(rBytes, sBytes []byte) bool {
+	if len(rBytes) != SignatureScalarSize || len(sBytes) != SignatureScalarSize {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(rBytes)
+	s := new(big.Int).SetBytes(sBytes)
+
+	return r.Sign() > 0 && r.Cmp(curveOrder) < 0 && s.Sign() > 0 && s.Cmp(halfCurveOrder) <= 0
+}
+
+// EncodeSignature liefert die 64-Byte-Kodierung R || S einer kanonischen Signatur
+func EncodeSignature(rBytes, sBytes []byte) ([]byte, error) {
+	if !IsCanonicalSignature(rBytes, sBytes) {
+		return nil, fmt.Errorf("signature is not canonical")
+	}
+	return append(append(make([]byte, 0, 2*SignatureScalarSize), rBytes...), sBytes...), nil
+}
+
+// DecodeSignature zerlegt eine 64-Byte-Signatur R || S und lehnt nicht-kanonische Signaturen ab
+func DecodeSignature(signature []byte) ([]byte, []byte, error) {
+	if len(signature) != 2*SignatureScalarSize {
+		return nil, nil, fmt.Errorf("invalid signature length %d", len(signature))
+	}
+
+	rBytes, sBytes := signature[:SignatureScalarSize], signature[SignatureScalarSize:]
+	if !IsCanonicalSignature(rBytes, sBytes) {
+		return nil, nil, fmt.Errorf("signature is not canonical")
+	}
+	return rBytes, sBytes, nil
+}
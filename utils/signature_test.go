@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Testvektor aus RFC 6979, Anhang A.2.5 (P-256, SHA-256, Nachricht "sample")
+func TestSignDeterministicRFC6979Vector(t *testing.T) {
+	d, _ := new(big.Int).SetString("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721", 16)
+	privKey := &ecdsa.PrivateKey{D: d}
+	privKey.Curve = elliptic.P256()
+	privKey.X, privKey.Y = elliptic.P256().ScalarBaseMult(d.Bytes())
+
+	digest := sha256.Sum256([]byte("sample"))
+	r, s, err := signDeterministic(privKey, digest[:])
+	require.NoError(t, err)
+
+	require.Equal(t, "efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716", hex.EncodeToString(r.Bytes()))
+	require.Equal(t, "f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8", hex.EncodeToString(s.Bytes()))
+
+	// Der Signer liefert die low-S-Variante n - s
+	rBytes, sBytes, err := NewMemorySigner(privKey).Sign(digest[:])
+	require.NoError(t, err)
+	require.Equal(t, r.FillBytes(make([]byte, 32)), rBytes)
+	require.Equal(t, new(big.Int).Sub(curveOrder, s).FillBytes(make([]byte, 32)), sBytes)
+}
+
+func TestSignDigestDeterministicAndCanonical(t *testing.T) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer := NewMemorySigner(privKey)
+	digest := sha256.Sum256([]byte("Transaction data to sign"))
+
+	r1, s1, err := SignDigest(signer, digest[:])
+	require.NoError(t, err)
+	r2, s2, err := SignDigest(signer, digest[:])
+	require.NoError(t, err)
+
+	require.Equal(t, r1, r2, "Signatures must be deterministic")
+	require.Equal(t, s1, s2, "Signatures must be deterministic")
+	require.Len(t, r1, SignatureScalarSize)
+	require.Len(t, s1, SignatureScalarSize)
+	require.True(t, VerifyDigest(&privKey.PublicKey, digest[:], r1, s1))
+
+	encoded, err := EncodeSignature(r1, s1)
+	require.NoError(t, err)
+	rDecoded, sDecoded, err := DecodeSignature(encoded)
+	require.NoError(t, err)
+	require.Equal(t, r1, rDecoded)
+	require.Equal(t, s1, sDecoded)
+}
+
+func TestVerifySignatureRejectsMalleatedSignatures(t *testing.T) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	hash := sha256.Sum256([]byte("Transaction data to sign"))
+
+	rBytes, sBytes, err := SignDigest(NewMemorySigner(privKey), hash[:])
+	require.NoError(t, err)
+
+	// n - s ist mathematisch gültig, muss aber als high-S abgelehnt werden
+	highS := new(big.Int).Sub(curveOrder, new(big.Int).SetBytes(sBytes)).FillBytes(make([]byte, 32))
+	require.True(t, ecdsa.Verify(&privKey.PublicKey, hash[:], new(big.Int).SetBytes(rBytes), new(big.Int).SetBytes(highS)))
+	require.False(t, VerifyDigest(&privKey.PublicKey, hash[:], rBytes, highS), "High-S signature must be rejected")
+
+	// Zusätzliche führende Null-Bytes ändern den Wert nicht, sind aber keine kanonische Kodierung
+	padded := append([]byte{0x00}, rBytes...)
+	require.False(t, VerifyDigest(&privKey.PublicKey, hash[:], padded, sBytes), "Non-canonical encoding must be rejected")
+
+	_, _, err = DecodeSignature(append(rBytes, highS...))
+	require.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Signer kapselt einen privaten Schlüssel, ohne ihn selbst herauszugeben.
+// Implementierungen können den Schlüssel im Speicher, in einem verschlüsselten
+// Keystore oder in einem HSM (PKCS#11) halten.
+type Signer interface {
+	// Sign signiert einen bereits berechneten Digest und gibt R und S zurück.
+	// Die Werte werden von SignDigest in die kanonische low-S-Form gebracht.
+	Sign(digest []byte) ([]byte, []byte, error)
+	// PublicKey liefert den zugehörigen öffentlichen Schlüssel
+	PublicKey() *ecdsa.PublicKey
+	// ECDH berechnet das gemeinsame Geheimnis mit dem öffentlichen Schlüssel der Gegenseite
+	ECDH(remote *ecdh.PublicKey) ([]byte, error)
+}
+
+// Umgebungsvariablen für Geheimnisse, die nicht als Flag übergeben werden sollen
+const (
+	KeystorePassphraseEnv = "EGA_KEYSTORE_PASSPHRASE"
+	PKCS11PinEnv          = "EGA_PKCS11_PIN"
+)
+
+// MemorySigner hält den privaten Schlüssel im Prozessspeicher und signiert
+// deterministisch nach RFC 6979
+type MemorySigner struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+func NewMemorySigner(privateKey *ecdsa.PrivateKey) *MemorySigner {
+	return &MemorySigner{privateKey: privateKey}
+}
+
+func (m *MemorySigner) Sign(digest []byte) ([]byte, []byte, error) {
+	r, s, err := signDeterministic(m.privateKey, digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NormalizeSignature(r.Bytes(), s.Bytes())
+}
+
+func (m *MemorySigner) PublicKey() *ecdsa.PublicKey {
+	return &m.privateKey.PublicKey
+}
+
+func (m *MemorySigner) ECDH(remote *ecdh.PublicKey) ([]byte, error) {
+	ecdhPrivKey, err := EcdsaPrivToEcdh(m.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return ecdhPrivKey.ECDH(remote)
+}
+
+// LoadSigner lädt einen Signer anhand einer Schlüsselreferenz:
+//   - "pkcs11:..." öffnet einen Schlüssel in einem PKCS#11-Token (PIN aus EGA_PKCS11_PIN)
+//   - eine Keystore-Datei (JSON) wird mit der Passphrase aus EGA_KEYSTORE_PASSPHRASE geöffnet
+//   - jede andere Datei wird als PEM-kodierter EC-Schlüssel geladen
+func LoadSigner(keyRef string) (Signer, error) {
+	if strings.HasPrefix(keyRef, "pkcs11:") {
+		config, err := ParsePKCS11URI(keyRef)
+		if err != nil {
+			return nil, err
+		}
+		if config.PIN == "" {
+			config.PIN = os.Getenv(PKCS11PinEnv)
+		}
+		return NewPKCS11Signer(config)
+	}
+
+	data, err := os.ReadFile(keyRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+
+	if IsKeystore(data) {
+		return NewKeystoreSigner(data, os.Getenv(KeystorePassphraseEnv))
+	}
+
+	privKey, _, err := LoadPrivateKey(keyRef)
+	if err != nil {
+		return nil, err
+	}
+	return NewMemorySigner(privKey), nil
+}
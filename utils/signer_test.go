@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Prüft, dass ein Signer signieren und verschlüsseln kann wie ein Schlüssel im Speicher
+func testSigner(t *testing.T, signer Signer) {
+	data := []byte("Transaction data to sign")
+	digest := DomainHash(DomainTransaction, "ega-test", data)
+
+	rBytes, sBytes, err := SignDigest(signer, digest)
+	require.NoError(t, err, "Error during signing")
+	require.True(t, VerifyDigest(signer.PublicKey(), digest, rBytes, sBytes), "Signature verification failed")
+
+	peerPrivKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Error generating peer private key")
+	peerEcdhPrivKey, err := EcdsaPrivToEcdh(peerPrivKey)
+	require.NoError(t, err)
+	peerEcdhPubKey, err := EcdsaPubToEcdh(&peerPrivKey.PublicKey)
+	require.NoError(t, err)
+	signerEcdhPubKey, err := EcdsaPubToEcdh(signer.PublicKey())
+	require.NoError(t, err)
+
+	ciphertext, nonce, err := EncryptDataWithSigner(signer, peerEcdhPubKey, data)
+	require.NoError(t, err, "Error during encryption")
+
+	plaintext, err := DecryptData(peerEcdhPrivKey, signerEcdhPubKey, ciphertext, nonce)
+	require.NoError(t, err, "Error during decryption")
+	require.Equal(t, data, plaintext)
+}
+
+func TestMemorySigner(t *testing.T) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	testSigner(t, NewMemorySigner(privKey))
+}
+
+func TestKeystoreSigner(t *testing.T) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	keystore, err := EncryptKeystore(privKey, "correct horse")
+	require.NoError(t, err)
+	require.True(t, IsKeystore(keystore))
+
+	_, err = NewKeystoreSigner(keystore, "wrong passphrase")
+	require.Error(t, err, "Keystore must not open with a wrong passphrase")
+
+	signer, err := NewKeystoreSigner(keystore, "correct horse")
+	require.NoError(t, err)
+	require.True(t, privKey.PublicKey.Equal(signer.PublicKey()))
+
+	testSigner(t, signer)
+}
+
+// Benötigt einen initialisierten SoftHSM-Token mit einem P-256-Schlüsselpaar, z.B.:
+//
+//	softhsm2-util --init-token --free --label ega --pin 1234 --so-pin 1234
+//	pkcs11-tool --module $EGA_TEST_PKCS11_MODULE --login --pin 1234 --keypairgen --key-type EC:prime256v1 --label authority
+func TestPKCS11Signer(t *testing.T) {
+	module := os.Getenv("EGA_TEST_PKCS11_MODULE")
+	if module == "" {
+		t.Skip("EGA_TEST_PKCS11_MODULE not set, skipping SoftHSM test")
+	}
+
+	signer, err := NewPKCS11Signer(PKCS11Config{
+		Module:     module,
+		TokenLabel: "ega",
+		KeyLabel:   "authority",
+		PIN:        os.Getenv(PKCS11PinEnv),
+	})
+	require.NoError(t, err)
+	defer signer.Close()
+
+	testSigner(t, signer)
+}
+
+func TestParsePKCS11URI(t *testing.T) {
+	config, err := ParsePKCS11URI("pkcs11:token=ega;object=authority?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-value=1234")
+	require.NoError(t, err)
+	require.Equal(t, PKCS11Config{
+		Module:     "/usr/lib/softhsm/libsofthsm2.so",
+		TokenLabel: "ega",
+		KeyLabel:   "authority",
+		PIN:        "1234",
+	}, config)
+
+	_, err = ParsePKCS11URI("pkcs11:token=ega")
+	require.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSFiles sind die PEM-Dateien für TLS: eigenes Zertifikat mit Schlüssel und die CA, der bei der
+// Gegenseite vertraut wird. Alle Felder sind optional.
+type TLSFiles struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// Enabled meldet, ob überhaupt TLS-Dateien angegeben sind
+func (f TLSFiles) Enabled() bool {
+	return f.CertFile != "" || f.KeyFile != "" || f.CAFile != ""
+}
+
+// ServerTLSConfig erstellt die Konfiguration für den HTTP- bzw. gRPC-Server. Mit CA werden
+// Client-Zertifikate geprüft und bei requireClientCert verlangt (mTLS).
+func ServerTLSConfig(files TLSFiles, requireClientCert bool) (*tls.Config, error) {
+	if files.CertFile == "" || files.KeyFile == "" {
+		return nil, fmt.Errorf("TLS requires both a certificate and a key")
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if err := loadCertificate(config, files); err != nil {
+		return nil, err
+	}
+
+	if files.CAFile != "" {
+		pool, err := loadCertPool(files.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if requireClientCert {
+		return nil, fmt.Errorf("mutual TLS requires a CA to verify client certificates")
+	}
+
+	return config, nil
+}
+
+// ClientTLSConfig erstellt die Konfiguration für Verbindungen zu einem Node. Ohne CA wird den
+// System-Zertifikaten vertraut, ein eigenes Zertifikat wird für mTLS mitgeschickt.
+func ClientTLSConfig(files TLSFiles) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if files.CertFile != "" || files.KeyFile != "" {
+		if err := loadCertificate(config, files); err != nil {
+			return nil, err
+		}
+	}
+
+	if files.CAFile != "" {
+		pool, err := loadCertPool(files.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+func loadCertificate(config *tls.Config, files TLSFiles) error {
+	certificate, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	config.Certificates = []tls.Certificate{certificate}
+	return nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+	}
+	return pool, nil
+}
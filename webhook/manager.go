@@ -0,0 +1,555 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+)
+
+// EventRecordIncluded wird ausgelöst, sobald eine Transaktion des Patienten in einen Block aufgenommen wurde
+const EventRecordIncluded = "record.included"
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// maxDeliveryLog begrenzt die Anzahl gespeicherter Zustellungen je Webhook
+const maxDeliveryLog = 100
+
+// DefaultRetryDelays sind die Wartezeiten vor dem zweiten, dritten, ... Zustellversuch
+var DefaultRetryDelays = []time.Duration{
+	5 * time.Second,
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+}
+
+// Webhook ist eine Registrierung für neue Einträge eines Patienten (KeyID des Patienten-Public-Keys)
+type Webhook struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	PatientID string `json:"patientId"`
+	Secret    string `json:"secret,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// Payload wird als JSON-Body zugestellt und enthält bewusst keine medizinischen Daten
+type Payload struct {
+	Event   string `json:"event"`
+	TxHash  string `json:"txHash"`
+	BlockID uint64 `json:"blockId"`
+}
+
+// Delivery ist ein Eintrag im Zustellprotokoll eines Webhooks
+type Delivery struct {
+	ID             string `json:"id"`
+	WebhookID      string `json:"webhookId"`
+	TxHash         string `json:"txHash"`
+	BlockID        uint64 `json:"blockId"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	LastStatusCode int    `json:"lastStatusCode,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+	CreatedAt      int64  `json:"createdAt"`
+	UpdatedAt      int64  `json:"updatedAt"`
+}
+
+// ErrPrivateTarget wird gemeldet, wenn ein Webhook auf eine interne Adresse zeigt
+var ErrPrivateTarget = errors.New("webhook target is a private, loopback or link-local address")
+
+// Manager verwaltet die Registrierungen und stellt Benachrichtigungen mit Wiederholungen zu.
+// Registrierungen und der nächste zu benachrichtigende Block werden neben path gespeichert (leer: nur im
+// Speicher), das Zustellprotokoll nur im Speicher.
+type Manager struct {
+	HTTPClient  *http.Client
+	RetryDelays []time.Duration
+	// AllowPrivateTargets erlaubt Webhooks an interne Adressen (Loopback, private Netze, Link-Local).
+	// Ohne diese Freigabe des Operators könnte jeder über den Node interne Dienste ansprechen.
+	AllowPrivateTargets bool
+
+	path       string
+	cursorPath string
+	webhooks   map[string]*Webhook
+	deliveries map[string][]*Delivery
+	notified   uint64         // nächster Block, für den noch keine Zustellungen gestartet wurden
+	inFlight   map[uint64]int // offene Zustellungen je Block
+	cursor     uint64         // zuletzt gespeicherter Wiederaufsetzpunkt
+	running    sync.WaitGroup
+	mutex      sync.Mutex
+}
+
+func NewManager(path string) (*Manager, error) {
+	m := &Manager{
+		RetryDelays: DefaultRetryDelays,
+		path:        path,
+		webhooks:    make(map[string]*Webhook),
+		deliveries:  make(map[string][]*Delivery),
+		inFlight:    make(map[uint64]int),
+	}
+
+	// Die Zieladresse wird erst beim Verbindungsaufbau geprüft, damit auch DNS-Namen, die auf interne
+	// Adressen zeigen, abgelehnt werden
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: m.checkDialTarget}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	m.HTTPClient = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+
+	if path == "" {
+		return m, nil
+	}
+	m.cursorPath = strings.TrimSuffix(path, filepath.Ext(path)) + ".cursor"
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks: %v", err)
+	}
+
+	var webhooks []*Webhook
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return nil, fmt.Errorf("failed to decode webhooks: %v", err)
+	}
+	for _, webhook := range webhooks {
+		m.webhooks[webhook.ID] = webhook
+	}
+	return m, nil
+}
+
+// Register legt einen Webhook an. Ohne Secret wird ein zufälliges erzeugt und einmalig zurückgegeben.
+func (m *Manager) Register(targetURL, patientID, secret string) (*Webhook, error) {
+	parsed, err := url.Parse(targetURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("webhook URL must be an absolute http(s) URL")
+	}
+	if !m.AllowPrivateTargets && isPrivateHost(parsed.Hostname()) {
+		return nil, ErrPrivateTarget
+	}
+	if patientID == "" {
+		return nil, fmt.Errorf("patient ID is required")
+	}
+
+	if secret == "" {
+		if secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &Webhook{
+		ID:        id,
+		URL:       targetURL,
+		PatientID: patientID,
+		Secret:    secret,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.webhooks[id] = webhook
+	if err := m.save(); err != nil {
+		delete(m.webhooks, id)
+		return nil, err
+	}
+
+	registered := *webhook
+	return &registered, nil
+}
+
+// List liefert alle Webhooks ohne Secret, sortiert nach Erstellungszeit
+func (m *Manager) List() []*Webhook {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	webhooks := make([]*Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		public := *webhook
+		public.Secret = ""
+		webhooks = append(webhooks, &public)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].CreatedAt != webhooks[j].CreatedAt {
+			return webhooks[i].CreatedAt < webhooks[j].CreatedAt
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks
+}
+
+// Get liefert einen Webhook ohne Secret
+func (m *Manager) Get(id string) (*Webhook, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	webhook, exists := m.webhooks[id]
+	if !exists {
+		return nil, false
+	}
+	public := *webhook
+	public.Secret = ""
+	return &public, true
+}
+
+func (m *Manager) Remove(id string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	webhook, exists := m.webhooks[id]
+	if !exists {
+		return false, nil
+	}
+
+	delete(m.webhooks, id)
+	if err := m.save(); err != nil {
+		m.webhooks[id] = webhook
+		return false, err
+	}
+	delete(m.deliveries, id)
+	return true, nil
+}
+
+// Deliveries liefert das Zustellprotokoll eines Webhooks, die neuesten Zustellungen zuerst
+func (m *Manager) Deliveries(id string) ([]Delivery, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.webhooks[id]; !exists {
+		return nil, false
+	}
+
+	log := m.deliveries[id]
+	deliveries := make([]Delivery, 0, len(log))
+	for i := len(log) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, *log[i])
+	}
+	return deliveries, true
+}
+
+// Watch benachrichtigt für alle neuen Blöcke. Nach einem Neustart setzt Watch beim ersten Block mit
+// nicht abgeschlossenen Zustellungen bzw. beim ersten noch nicht benachrichtigten Block wieder auf. Beim
+// ersten Start gelten erst die Blöcke ab der Höhe aus synced als neu (nil: ab der aktuellen Höhe), damit
+// ein Node beim erstmaligen Synchronisieren nicht die gesamte Historie meldet. Blockiert, bis ctx endet,
+// und wartet dann, bis die laufenden Zustellungen abgebrochen sind.
+func (m *Manager) Watch(ctx context.Context, bc *blockchain.Blockchain, synced <-chan uint64) {
+	defer m.running.Wait()
+
+	height := uint64(bc.Len())
+	if synced != nil {
+		select {
+		case height = <-synced:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	next := m.resume(height)
+	for {
+		changed := bc.Changed()
+
+		blocks := bc.BlockRange(next, 100)
+		for _, block := range blocks {
+			m.NotifyBlock(ctx, block)
+			next = block.ID + 1
+		}
+		if len(blocks) > 0 {
+			continue
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// resume liefert den Block, ab dem benachrichtigt wird: den gespeicherten Wiederaufsetzpunkt oder height
+func (m *Manager) resume(height uint64) uint64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	next := height
+	if data, err := os.ReadFile(m.cursorPath); err == nil {
+		var cursor struct {
+			NextBlock uint64 `json:"nextBlock"`
+		}
+		if err := json.Unmarshal(data, &cursor); err == nil && cursor.NextBlock <= height {
+			next = cursor.NextBlock
+		}
+	} else {
+		// Ohne gespeicherten Stand sofort festhalten, sonst übersprünge ein Neustart die Blöcke dazwischen
+		m.writeCursor(next)
+	}
+	m.notified, m.cursor = next, next
+	return next
+}
+
+// NotifyBlock startet die Zustellungen für alle Transaktionen des Blocks mit registriertem Patienten.
+// Die Zustellungen enden mit ctx, noch ausstehende werden dann beim nächsten Start wiederholt.
+func (m *Manager) NotifyBlock(ctx context.Context, block *blockchain.Block) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, tx := range block.Transactions {
+		patientID := blockchain.KeyID(tx.Patient)
+		for _, webhook := range m.webhooks {
+			if webhook.PatientID != patientID {
+				continue
+			}
+
+			deliveryID, err := randomHex(8)
+			if err != nil {
+				continue
+			}
+			now := time.Now().Unix()
+			delivery := &Delivery{
+				ID:        deliveryID,
+				WebhookID: webhook.ID,
+				TxHash:    hex.EncodeToString(tx.Hash),
+				BlockID:   block.ID,
+				Status:    DeliveryPending,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			m.appendDelivery(delivery)
+
+			m.inFlight[block.ID]++
+			m.running.Add(1)
+			go func(webhook Webhook) {
+				defer m.running.Done()
+				if m.deliver(ctx, webhook, delivery) {
+					m.finish(delivery.BlockID)
+				}
+			}(*webhook)
+		}
+	}
+
+	m.notified = max(m.notified, block.ID+1)
+	m.saveCursor()
+}
+
+// deliver stellt die Benachrichtigung zu und wiederholt fehlgeschlagene Versuche gemäß RetryDelays.
+// Das Ergebnis ist false, wenn ctx vor dem Abschluss endet.
+func (m *Manager) deliver(ctx context.Context, webhook Webhook, delivery *Delivery) bool {
+	body, err := json.Marshal(Payload{
+		Event:   EventRecordIncluded,
+		TxHash:  delivery.TxHash,
+		BlockID: delivery.BlockID,
+	})
+	if err != nil {
+		m.updateDelivery(delivery, DeliveryFailed, 0, err)
+		return true
+	}
+
+	for attempt := 0; ; attempt++ {
+		statusCode, err := m.post(ctx, webhook, delivery.ID, body)
+		if ctx.Err() != nil {
+			return false
+		}
+		if err == nil {
+			m.updateDelivery(delivery, DeliveryDelivered, statusCode, nil)
+			return true
+		}
+
+		if attempt >= len(m.RetryDelays) {
+			m.updateDelivery(delivery, DeliveryFailed, statusCode, err)
+			return true
+		}
+		m.updateDelivery(delivery, DeliveryPending, statusCode, err)
+
+		select {
+		case <-time.After(m.RetryDelays[attempt]):
+		case <-ctx.Done():
+			return false
+		}
+
+		// Zwischenzeitlich gelöschte Webhooks werden nicht weiter beliefert
+		m.mutex.Lock()
+		_, exists := m.webhooks[webhook.ID]
+		m.mutex.Unlock()
+		if !exists {
+			return true
+		}
+	}
+}
+
+// finish vermerkt eine abgeschlossene Zustellung und rückt den Wiederaufsetzpunkt vor
+func (m *Manager) finish(blockID uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.inFlight[blockID]--; m.inFlight[blockID] <= 0 {
+		delete(m.inFlight, blockID)
+	}
+	m.saveCursor()
+}
+
+// saveCursor speichert den ersten Block mit offenen Zustellungen bzw. den nächsten nicht benachrichtigten.
+// Der Aufrufer muss den Mutex halten.
+func (m *Manager) saveCursor() {
+	cursor := m.notified
+	for blockID := range m.inFlight {
+		cursor = min(cursor, blockID)
+	}
+	if cursor != m.cursor {
+		m.writeCursor(cursor)
+	}
+}
+
+// writeCursor speichert den Wiederaufsetzpunkt. Der Aufrufer muss den Mutex halten.
+func (m *Manager) writeCursor(cursor uint64) {
+	if m.cursorPath == "" {
+		return
+	}
+
+	data, err := json.Marshal(map[string]uint64{"nextBlock": cursor})
+	if err == nil {
+		err = writeFileAtomic(m.cursorPath, data)
+	}
+	if err != nil {
+		fmt.Printf("Fehler beim Speichern des Webhook-Fortschritts: %v\n", err)
+		return
+	}
+	m.cursor = cursor
+}
+
+func (m *Manager) post(ctx context.Context, webhook Webhook, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-EGA-Event", EventRecordIncluded)
+	req.Header.Set("X-EGA-Delivery", deliveryID)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, time.Now().Unix(), body))
+
+	resp, err := m.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (m *Manager) updateDelivery(delivery *Delivery, status string, statusCode int, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delivery.Status = status
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+	delivery.UpdatedAt = time.Now().Unix()
+}
+
+// appendDelivery fügt eine Zustellung ins Protokoll ein, der Aufrufer muss den Mutex halten
+func (m *Manager) appendDelivery(delivery *Delivery) {
+	log := append(m.deliveries[delivery.WebhookID], delivery)
+	if len(log) > maxDeliveryLog {
+		log = log[len(log)-maxDeliveryLog:]
+	}
+	m.deliveries[delivery.WebhookID] = log
+}
+
+// save schreibt alle Registrierungen atomar in die Datei, der Aufrufer muss den Mutex halten
+func (m *Manager) save() error {
+	if m.path == "" {
+		return nil
+	}
+
+	webhooks := make([]*Webhook, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+	data, err := json.MarshalIndent(webhooks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize webhooks: %v", err)
+	}
+
+	// Die Datei enthält die Secrets und ist nur für den Node lesbar
+	if err := writeFileAtomic(m.path, data); err != nil {
+		return fmt.Errorf("failed to write webhooks: %v", err)
+	}
+	return nil
+}
+
+// writeFileAtomic ersetzt die Datei über eine temporäre Datei, nur für den Node lesbar
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// checkDialTarget lehnt Verbindungen zu internen Adressen ab, solange sie nicht freigegeben sind
+func (m *Manager) checkDialTarget(network, address string, conn syscall.RawConn) error {
+	if m.AllowPrivateTargets {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, host)
+	}
+	return nil
+}
+
+// isPrivateHost erkennt interne Ziele schon bei der Registrierung: IP-Adressen und localhost.
+// Namen, die erst per DNS auf interne Adressen zeigen, lehnt checkDialTarget ab.
+func isPrivateHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return isPrivateIP(ip)
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified()
+}
+
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
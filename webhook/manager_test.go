@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MalcolmFuchs/Go-Blockchain-Bachelor/blockchain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryIsSignedAndRetried(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan Payload, 1)
+
+	var secret string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, Verify(secret, r.Header.Get(SignatureHeader), body, time.Minute))
+
+		// Der erste Versuch schlägt fehl und muss wiederholt werden
+		if calls.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		received <- payload
+	}))
+	defer server.Close()
+
+	manager, err := NewManager("")
+	require.NoError(t, err)
+	manager.RetryDelays = []time.Duration{10 * time.Millisecond}
+	manager.AllowPrivateTargets = true
+
+	patient := []byte("patient-public-key")
+	webhook, err := manager.Register(server.URL, blockchain.KeyID(patient), "")
+	require.NoError(t, err)
+	secret = webhook.Secret
+
+	manager.NotifyBlock(context.Background(), &blockchain.Block{
+		ID: 7,
+		Transactions: []*blockchain.Transaction{
+			{Hash: []byte{0xab, 0xcd}, Patient: patient},
+			{Hash: []byte{0xef}, Patient: []byte("someone-else")},
+		},
+	})
+
+	select {
+	case payload := <-received:
+		require.Equal(t, Payload{Event: EventRecordIncluded, TxHash: "abcd", BlockID: 7}, payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	require.Eventually(t, func() bool {
+		deliveries, _ := manager.Deliveries(webhook.ID)
+		return len(deliveries) == 1 && deliveries[0].Status == DeliveryDelivered
+	}, 5*time.Second, 10*time.Millisecond)
+
+	deliveries, _ := manager.Deliveries(webhook.ID)
+	require.Equal(t, 2, deliveries[0].Attempts)
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestVerifyRejectsTamperedPayload(t *testing.T) {
+	body := []byte(`{"event":"record.included","txHash":"abcd","blockId":1}`)
+	header := Sign("secret", time.Now().Unix(), body)
+
+	require.NoError(t, Verify("secret", header, body, time.Minute))
+	require.Error(t, Verify("other", header, body, time.Minute))
+	require.Error(t, Verify("secret", header, []byte(`{"blockId":2}`), time.Minute))
+	require.Error(t, Verify("secret", Sign("secret", time.Now().Add(-time.Hour).Unix(), body), body, time.Minute))
+}
+
+func TestRegistrationsArePersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+
+	manager, err := NewManager(path)
+	require.NoError(t, err)
+	webhook, err := manager.Register("https://portal.example/hook", "patient", "secret")
+	require.NoError(t, err)
+	_, err = manager.Register("ftp://portal.example", "patient", "")
+	require.Error(t, err)
+
+	reloaded, err := NewManager(path)
+	require.NoError(t, err)
+	webhooks := reloaded.List()
+	require.Len(t, webhooks, 1)
+	require.Equal(t, webhook.ID, webhooks[0].ID)
+	require.Empty(t, webhooks[0].Secret, "secrets must not be listed")
+
+	removed, err := reloaded.Remove(webhook.ID)
+	require.NoError(t, err)
+	require.True(t, removed)
+}
+
+// Beim Beenden werden wartende Zustellungen abgebrochen und nach dem Neustart ab ihrem Block wiederholt
+func TestDeliveriesAreCancelledAndResumed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	manager, err := NewManager(path)
+	require.NoError(t, err)
+	manager.RetryDelays = []time.Duration{time.Hour}
+	manager.AllowPrivateTargets = true
+
+	patient := []byte("patient-public-key")
+	webhook, err := manager.Register(server.URL, blockchain.KeyID(patient), "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.Equal(t, uint64(5), manager.resume(5))
+	manager.NotifyBlock(ctx, &blockchain.Block{ID: 5})
+	manager.NotifyBlock(ctx, &blockchain.Block{ID: 6, Transactions: []*blockchain.Transaction{{Hash: []byte{0xab}, Patient: patient}}})
+	manager.NotifyBlock(ctx, &blockchain.Block{ID: 7})
+
+	require.Eventually(t, func() bool {
+		deliveries, _ := manager.Deliveries(webhook.ID)
+		return len(deliveries) == 1 && deliveries[0].Attempts == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		manager.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery was not cancelled")
+	}
+
+	// Block 6 ist noch offen, auch wenn Block 7 bereits benachrichtigt wurde
+	reloaded, err := NewManager(path)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), reloaded.resume(10))
+
+	// Ein Wiederaufsetzpunkt hinter der gespeicherten Chain wird ignoriert
+	reloaded, err = NewManager(path)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), reloaded.resume(3))
+}
+
+func TestPrivateTargetsAreRejected(t *testing.T) {
+	manager, err := NewManager("")
+	require.NoError(t, err)
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := manager.Register(target, "patient", "")
+		require.ErrorIs(t, err, ErrPrivateTarget, target)
+	}
+
+	// Namen, die erst beim Verbindungsaufbau auf interne Adressen zeigen, werden beim Dial abgelehnt
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	_, err = manager.post(context.Background(), Webhook{URL: server.URL}, "delivery", []byte("{}"))
+	require.ErrorIs(t, err, ErrPrivateTarget)
+	require.Zero(t, calls.Load())
+
+	manager.AllowPrivateTargets = true
+	_, err = manager.Register(server.URL, "patient", "")
+	require.NoError(t, err)
+}
+
+// Der Startpunkt des ersten Starts wird sofort gespeichert, ein Neustart überspringt keine Blöcke
+func TestFirstResumePointIsSaved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	manager, err := NewManager(path)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), manager.resume(4))
+
+	reloaded, err := NewManager(path)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), reloaded.resume(9))
+}
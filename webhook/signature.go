@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader enthält Zeitstempel und HMAC-SHA256 des Payloads: "t=<unix>,v1=<hex>"
+const SignatureHeader = "X-EGA-Signature"
+
+// Sign berechnet die Signatur über "<timestamp>.<body>", der Zeitstempel schützt vor Replays
+func Sign(secret string, timestamp int64, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(computeMAC(secret, timestamp, body)))
+}
+
+// Verify prüft den Signatur-Header eines empfangenen Webhooks. Liegt der Zeitstempel weiter als
+// tolerance in der Vergangenheit oder Zukunft, wird der Aufruf abgelehnt.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp int64
+	var signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid signature timestamp: %v", err)
+			}
+			timestamp = parsed
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == 0 || signature == "" {
+		return fmt.Errorf("malformed signature header")
+	}
+
+	age := time.Since(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp outside tolerance")
+	}
+
+	mac, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, computeMAC(secret, timestamp, body)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func computeMAC(secret string, timestamp int64, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return mac.Sum(nil)
+}